@@ -0,0 +1,150 @@
+package exports
+
+import (
+	"fmt"
+	"go/types"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// abiSensitive lists symbols whose signatures and layouts must be the same on every
+// platform, like those plugin hosts share with binaries built elsewhere. * stands for
+// every exported symbol. Snapshots record the mark, see Symbol.ABISensitive.
+var abiSensitive stringList
+
+// defaultPlatformTypes are the types checkPlatformTypes looks for unless -platform-types is given.
+var defaultPlatformTypes = stringList{"int", "uint", "uintptr", "syscall.*"}
+
+// qualifiedNamePattern matches the possibly qualified type names in a type expression.
+var qualifiedNamePattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?`)
+
+func (l stringList) marksABISensitive(sym Symbol) bool {
+	return !sym.Unexported && (l.contains("*") || l.matches(sym))
+}
+
+// platformType returns the name of the first platform dependent type expr uses, if any.
+func (p Policy) platformType(expr string) string {
+	names := p.PlatformTypes
+	if len(names) == 0 {
+		names = defaultPlatformTypes
+	}
+	for _, name := range qualifiedNamePattern.FindAllString(expr, -1) {
+		for _, pattern := range names {
+			if name == pattern || strings.HasSuffix(pattern, ".*") && strings.HasPrefix(name, strings.TrimSuffix(pattern, "*")) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+var sizedKinds = map[string]types.BasicKind{"int": types.Int, "uint": types.Uint, "uintptr": types.Uintptr}
+
+// platformSizes describes how a platform dependent type differs across -platforms. It
+// returns "" for sized types like int that have the same size on every platform checked.
+func platformSizes(name string) string {
+	kind, ok := sizedKinds[name]
+	if !ok {
+		return "declared separately for each platform"
+	}
+	pairs := platforms
+	if len(pairs) == 0 {
+		pairs = defaultPlatforms
+	}
+	bySize := make(map[int64][]string)
+	for _, platform := range pairs {
+		_, goarch, _ := strings.Cut(platform, "/")
+		if sizes := types.SizesFor("gc", goarch); sizes != nil {
+			size := sizes.Sizeof(types.Typ[kind])
+			bySize[size] = append(bySize[size], platform)
+		}
+	}
+	if len(bySize) < 2 {
+		return ""
+	}
+	sizes := make([]int64, 0, len(bySize))
+	for size := range bySize {
+		sizes = append(sizes, size)
+	}
+	// the most common size comes last, as the one of the other platforms
+	sort.Slice(sizes, func(i, j int) bool { return len(bySize[sizes[i]]) < len(bySize[sizes[j]]) })
+	parts := make([]string, 0, len(sizes))
+	for _, size := range sizes[:len(sizes)-1] {
+		parts = append(parts, fmt.Sprintf("%d bytes on %s", size, strings.Join(bySize[size], ", ")))
+	}
+	return fmt.Sprintf("%s and %d bytes on the other platforms", strings.Join(parts, ", "), sizes[len(sizes)-1])
+}
+
+// writtenType is the type expression a symbol stands for, as written.
+func writtenType(sym *Symbol) string {
+	if sym.Label != "" && sym.SymbolType != "type" && sym.SymbolType != "member" && sym.SymbolType != "alias" {
+		return sym.Label
+	}
+	return sym.UnderlyingType
+}
+
+// typeUses lists where sym refers to types, by the path reports name them with.
+func typeUses(sym *Symbol) map[string]string {
+	res := make(map[string]string)
+	if sym.FuncSpec != nil {
+		for i := range sym.FuncSpec.Params {
+			res[fmt.Sprintf("param %d", i)] = writtenType(&sym.FuncSpec.Params[i])
+		}
+		for i := range sym.FuncSpec.Returns {
+			res[fmt.Sprintf("result %d", i)] = writtenType(&sym.FuncSpec.Returns[i])
+		}
+	}
+	for i := range sym.Members {
+		if sym.SymbolType == "struct" {
+			res["field "+sym.Members[i].Label] = writtenType(&sym.Members[i])
+		}
+	}
+	switch {
+	case sym.ValueType != nil:
+		res["type"] = writtenType(sym.ValueType)
+	case sym.SymbolType == "type" || sym.SymbolType == "alias":
+		res["underlying type"] = sym.UnderlyingType
+	}
+	return res
+}
+
+// checkPlatformTypes warns about ABI sensitive symbols, marked so in the reference or
+// with -abi-sensitive, whose declarations use types that differ between platforms.
+func (p Policy) checkPlatformTypes(reference, current SymbolList) []Diff {
+	marked := make(map[string]bool)
+	for _, sym := range reference {
+		if sym.ABISensitive {
+			marked[sym.Ident()] = true
+		}
+	}
+	res := make([]Diff, 0)
+	for i := range current {
+		sym := &current[i]
+		if sym.Unexported || !marked[sym.Ident()] && !abiSensitive.marksABISensitive(*sym) {
+			continue
+		}
+		uses := typeUses(sym)
+		paths := make([]string, 0, len(uses))
+		for path := range uses {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			name := p.platformType(uses[path])
+			if name == "" {
+				continue
+			}
+			if sizes := platformSizes(name); sizes != "" {
+				res = append(res, Diff{
+					Kind:     DiffPlatform,
+					Symbol:   sym.Ident(),
+					Message:  fmt.Sprintf("%s uses %s, which is %s", path, name, sizes),
+					Severity: SeverityWarning,
+					New:      sym,
+				})
+			}
+		}
+	}
+	return res
+}
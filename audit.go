@@ -0,0 +1,198 @@
+package exports
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// auditFile is what extraction makes of a file in the work dir.
+type auditFile struct {
+	Name string
+	// Skipped tells why the file is not read, empty for files that are
+	Skipped string
+	// Notes are facts about a read file that may explain surprising symbols
+	Notes []string
+}
+
+// isGenerated reports whether a file carries the standard generated code comment.
+func isGenerated(file *ast.File) bool {
+	for _, group := range file.Comments {
+		if group.Pos() >= file.Package {
+			break
+		}
+		for _, comment := range group.List {
+			if strings.HasPrefix(comment.Text, "// Code generated ") && strings.HasSuffix(comment.Text, " DO NOT EDIT.") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// buildConstraint returns the //go:build expression of a file, if any.
+func buildConstraint(file *ast.File) string {
+	for _, group := range file.Comments {
+		if group.Pos() >= file.Package {
+			break
+		}
+		for _, comment := range group.List {
+			if constraint.IsGoBuild(comment.Text) {
+				if expr, err := constraint.Parse(comment.Text); err == nil {
+					return expr.String()
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// auditFiles classifies every file of dir the way extract selects them.
+func auditFiles(dir, pkgName string) ([]auditFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	selected := pkgName
+	if selected == "" {
+		if selected, err = packageName(dir, ""); err != nil {
+			return nil, err
+		}
+	}
+	res := make([]auditFile, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		audit := auditFile{Name: entry.Name()}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case !strings.HasSuffix(entry.Name(), ".go"):
+			audit.Skipped = "not a Go file"
+		case !isSourceFile(info):
+			audit.Skipped = "test file"
+		case !isChangedFile(entry.Name()):
+			audit.Skipped = "not among the files given with -changed-only"
+		case !builtForTarget(dir, entry.Name()):
+			audit.Skipped = "not built for " + targetName()
+		}
+		if audit.Skipped == "" {
+			file, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, entry.Name()), nil, parser.ParseComments|parser.SkipObjectResolution)
+			if err != nil {
+				return nil, err
+			}
+			if file.Name.Name != selected {
+				audit.Skipped = fmt.Sprintf("package %s, not %s", file.Name.Name, selected)
+			}
+			if expr := buildConstraint(file); expr != "" {
+				// without a target, files are read regardless of build constraints, see buildVariants
+				audit.Notes = append(audit.Notes, "build constraint "+expr)
+			}
+			if isGenerated(file) {
+				audit.Notes = append(audit.Notes, "generated")
+			}
+		}
+		res = append(res, audit)
+	}
+	return res, nil
+}
+
+// excludedDecls lists the package level declarations of dir extraction leaves out, and why.
+func excludedDecls(dir, pkgName string) ([]string, error) {
+	fset := token.NewFileSet()
+	filter := func(info os.FileInfo) bool {
+		return sourceFilter(dir)(info) && isChangedFile(info.Name())
+	}
+	pkgs, err := parser.ParseDir(fset, dir, filter, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0)
+	if len(pkgs) == 0 && changedFiles != nil {
+		return res, nil
+	}
+	pkg, err := selectPackage(pkgs, dir, pkgName)
+	if err != nil {
+		return nil, err
+	}
+	exclude := func(pos token.Pos, kind string, name *ast.Ident) {
+		if includeUnexported || name.IsExported() {
+			return
+		}
+		why := "unexported, use -all to include"
+		if name.Name == "_" {
+			why = "blank identifier"
+		}
+		position := fset.Position(pos)
+		res = append(res, fmt.Sprintf("%s:%d %s %s: %s", relativePath(position.Filename), position.Line, kind, name.Name, why))
+	}
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				kind := "func"
+				if decl.Recv != nil {
+					kind = "method " + findReceiver(decl) + "."
+				}
+				exclude(decl.Pos(), kind, decl.Name)
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						exclude(spec.Pos(), "type", spec.Name)
+					case *ast.ValueSpec:
+						for _, name := range spec.Names {
+							exclude(name.Pos(), decl.Tok.String(), name)
+						}
+					}
+				}
+			}
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// writeAudit explains which files and declarations extraction reads, to debug why an
+// expected symbol is missing from snapshots.
+func writeAudit(w io.Writer, dir, pkgName string) error {
+	files, err := auditFiles(dir, pkgName)
+	if err != nil {
+		return err
+	}
+	excluded, err := excludedDecls(dir, pkgName)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "files read:")
+	for _, file := range files {
+		if file.Skipped == "" {
+			line := "\t" + file.Name
+			if len(file.Notes) > 0 {
+				line += " (" + strings.Join(file.Notes, ", ") + ")"
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+	fmt.Fprintln(w, "files skipped:")
+	for _, file := range files {
+		if file.Skipped != "" {
+			fmt.Fprintf(w, "\t%s: %s\n", file.Name, file.Skipped)
+		}
+	}
+	fmt.Fprintln(w, "declarations excluded:")
+	for _, line := range excluded {
+		fmt.Fprintf(w, "\t%s\n", line)
+	}
+	return nil
+}
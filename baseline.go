@@ -0,0 +1,318 @@
+package exports
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// baselineSchema is the schema version snapshots are written with. Every schema
+// ever written has a decoder in baselineDecoders, so old snapshots keep loading.
+const baselineSchema = 2
+
+// Baseline is a snapshot of the exported symbols of a package together with its provenance.
+type Baseline struct {
+	// Schema is the version of the snapshot format, see baselineSchema
+	Schema int           `json:"schema,omitempty"`
+	Meta   *BaselineMeta `json:"meta,omitempty"`
+	// Package is the identity of the package, if recorded with -identity
+	Package *PackageIdentity `json:"package,omitempty"`
+	Symbols SymbolList       `json:"symbols"`
+	// Packages are the snapshots of every package of a module, by import path, for
+	// snapshots taken with -r
+	Packages map[string]*Baseline `json:"packages,omitempty"`
+	// bare is set for snapshots written as a bare symbol list
+	bare bool
+	// prefix is the JSON Pointer of a package snapshot within its module snapshot
+	prefix string
+	// tree is set for symbols extracted from a source tree, which has no document to point into
+	tree bool
+}
+
+// pointer turns a JSON Pointer relative to source, the symbols of b compared, into a
+// pointer into the snapshot document. Source is a subset of the symbols with -changed-only.
+func (b *Baseline) pointer(source SymbolList, relative string) string {
+	if relative == "" || b.tree {
+		return ""
+	}
+	root := b.prefix + "/symbols"
+	if b.bare {
+		root = ""
+	}
+	index, rest := relative[1:], ""
+	if i := strings.Index(index, "/"); i >= 0 {
+		index, rest = index[:i], index[i:]
+	}
+	i, err := strconv.Atoi(index)
+	if err != nil || i >= len(source) {
+		// additions point at the end of the list
+		return root + relative
+	}
+	for j := range b.Symbols {
+		if b.Symbols[j].Ident() == source[i].Ident() && b.Symbols[j].FileName == source[i].FileName && b.Symbols[j].Pos == source[i].Pos {
+			return fmt.Sprintf("%s/%d%s", root, j, rest)
+		}
+	}
+	return root + relative
+}
+
+// BaselineMeta records who took a snapshot, when, from which commit and why.
+type BaselineMeta struct {
+	GeneratedBy string    `json:"generatedBy,omitempty"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	Commit      string    `json:"commit,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	// Docs is set when doc comment information was recorded, see -docs
+	Docs bool `json:"docs,omitempty"`
+	// Tags is set when struct field tags were recorded
+	Tags bool `json:"tags,omitempty"`
+	// Receivers is set when pointer receivers of methods were recorded
+	Receivers bool `json:"receivers,omitempty"`
+	// Consts is set when constants were told from variables, and ConstValues when
+	// their values were recorded, see -const-values
+	Consts      bool `json:"consts,omitempty"`
+	ConstValues bool `json:"constValues,omitempty"`
+	// ZeroValues is set when the usability of zero values was recorded, see -zero-values
+	ZeroValues bool `json:"zeroValues,omitempty"`
+	// Constraints is set when the //go:build expressions of files were recorded
+	Constraints bool `json:"constraints,omitempty"`
+	// Params is set when parameters and results sharing a type, like a, b int, were
+	// recorded one by one
+	Params bool `json:"params,omitempty"`
+	// Embeds is set when the methods of interfaces embedded in interfaces were recorded
+	Embeds bool `json:"embeds,omitempty"`
+	// Fields is set when struct fields sharing a type, like X, Y int, were recorded one by one
+	Fields bool `json:"fields,omitempty"`
+	// Target is the platform and build tags files were selected for, see -goos, empty
+	// when files of every platform were read
+	Target string `json:"target,omitempty"`
+	// Contract is the major version the snapshot is the contract of, like v2,
+	// and FrozenOn the date, as 2006-01-02, it was frozen on
+	Contract string `json:"contract,omitempty"`
+	FrozenOn string `json:"frozenOn,omitempty"`
+}
+
+// recorded notes what snapshots taken with the current settings record.
+func (m *BaselineMeta) recorded() {
+	m.Docs = captureDocs
+	m.Tags = true
+	m.Constraints = true
+	m.ZeroValues = zeroValues
+	m.Receivers = true
+	m.Consts = true
+	m.ConstValues = constValues
+	m.Params = true
+	m.Embeds = true
+	m.Fields = true
+	m.Target = targetName()
+}
+
+func (m BaselineMeta) String() string {
+	res := "snapshot taken " + m.GeneratedAt.Format(time.RFC3339)
+	if m.GeneratedAt.IsZero() {
+		res = "canonical snapshot"
+	}
+	if m.Contract != "" {
+		res = fmt.Sprintf("%s contract, frozen %s, %s", m.Contract, m.FrozenOn, res)
+	}
+	if m.Target != "" {
+		res += " for " + m.Target
+	}
+	if m.GeneratedBy != "" {
+		res += " by " + m.GeneratedBy
+	}
+	if m.Commit != "" {
+		res += " at commit " + m.Commit
+	}
+	if m.Reason != "" {
+		res += ": " + m.Reason
+	}
+	return res
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// newBaselineMeta collects provenance from the environment and the git repository containing dir, if any.
+func newBaselineMeta(dir, reason string) *BaselineMeta {
+	meta := &BaselineMeta{
+		GeneratedAt: time.Now().UTC().Truncate(time.Second),
+		Reason:      reason,
+	}
+	if name, err := git(dir, "config", "user.name"); err == nil && name != "" {
+		meta.GeneratedBy = name
+		if email, err := git(dir, "config", "user.email"); err == nil && email != "" {
+			meta.GeneratedBy += " <" + email + ">"
+		}
+	} else {
+		meta.GeneratedBy = os.Getenv("USER")
+	}
+	if commit, err := git(dir, "rev-parse", "HEAD"); err == nil {
+		meta.Commit = commit
+		if status, err := git(dir, "status", "--porcelain", "--", "."); err == nil && status != "" {
+			meta.Commit += "-dirty"
+		}
+	}
+	return meta
+}
+
+// contractPattern matches major versions contracts are stamped with.
+var contractPattern = regexp.MustCompile(`^v(\d+)$`)
+
+// stamp marks the snapshot as the contract of a major version, frozen on the given
+// date or, by default, on the day the snapshot is taken.
+func (m *BaselineMeta) stamp(contract, frozenOn string) error {
+	if !contractPattern.MatchString(contract) {
+		return fmt.Errorf("contract must be a major version like v2, got %s", contract)
+	}
+	if frozenOn == "" {
+		frozenOn = m.GeneratedAt.Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", frozenOn); err != nil {
+		return fmt.Errorf("frozen date must be like 2006-01-02, got %s", frozenOn)
+	}
+	m.Contract, m.FrozenOn = contract, frozenOn
+	return nil
+}
+
+// nextMajor is the major version following the contract, which breaking changes target.
+func (m *BaselineMeta) nextMajor() string {
+	if m == nil {
+		return ""
+	}
+	match := contractPattern.FindStringSubmatch(m.Contract)
+	if match == nil {
+		return ""
+	}
+	major, _ := strconv.Atoi(match[1])
+	return fmt.Sprintf("v%d", major+1)
+}
+
+// loadReference reads a snapshot from a file, or the snapshot of a package from a
+// module snapshot for references like file#import/path. A directory is extracted
+// as a source tree instead, and git:ref the package as of a git ref.
+func loadReference(reference string) (*Baseline, error) {
+	if strings.HasPrefix(reference, gitRefPrefix) {
+		return loadGitRef(strings.TrimPrefix(reference, gitRefPrefix))
+	}
+	if info, err := os.Stat(reference); err == nil && info.IsDir() {
+		return loadTree(reference)
+	}
+	fileName, importPath, ok := splitPackageReference(reference)
+	refDataBytes, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	refData, err := parseBaseline(fileName, refDataBytes)
+	if err != nil || !ok {
+		return refData, err
+	}
+	pkg := refData.Packages[importPath]
+	if pkg == nil {
+		return nil, fmt.Errorf("module snapshot %s has no package %s", fileName, importPath)
+	}
+	pkg.Meta, pkg.prefix = refData.Meta, "/packages/"+pointerEscaper.Replace(importPath)
+	return pkg, nil
+}
+
+// baselineDecoders decode each schema version of snapshots:
+//
+//	0: a bare list of symbols, written before provenance was recorded
+//	1: symbols with provenance, written before schema versions were recorded
+//	2: the current schema
+var baselineDecoders = map[int]func(data []byte) (*Baseline, error){
+	0: func(data []byte) (*Baseline, error) {
+		res := &Baseline{bare: true}
+		return res, json.Unmarshal(data, &res.Symbols)
+	},
+	1: decodeBaseline,
+	2: decodeBaseline,
+}
+
+func decodeBaseline(data []byte) (*Baseline, error) {
+	res := new(Baseline)
+	return res, json.Unmarshal(data, res)
+}
+
+// schemaOf tells the schema version of a snapshot from its contents.
+func schemaOf(data []byte) (int, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		return 0, nil
+	}
+	var probe struct {
+		Schema int `json:"schema"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return 0, err
+	}
+	if probe.Schema == 0 {
+		return 1, nil
+	}
+	return probe.Schema, nil
+}
+
+// parseBaseline decodes a snapshot of any schema version into the current one.
+func parseBaseline(name string, data []byte) (*Baseline, error) {
+	schema, err := schemaOf(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse reference data %s: %v", name, err)
+	}
+	decode, ok := baselineDecoders[schema]
+	if !ok {
+		return nil, fmt.Errorf("reference data %s has schema version %d, which is newer than this release supports, upgrade to read it", name, schema)
+	}
+	refData, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse reference data %s of schema version %d: %v", name, schema, err)
+	}
+	refData.Schema = baselineSchema
+	if err := refData.checkDuplicates(name); err != nil {
+		return nil, err
+	}
+	return refData, nil
+}
+
+// checkDuplicates fails on a symbol listed twice, as happens when snapshots are merged by
+// hand, since only one of the entries would be compared. Build variants, declarations
+// of a symbol in files for different platforms, are listed once for every file, or once
+// for every declaration in snapshots without files.
+func (b *Baseline) checkDuplicates(name string) error {
+	root := b.prefix + "/symbols"
+	if b.bare {
+		root = ""
+	}
+	seen := make(map[string]int)
+	for i, sym := range b.Symbols {
+		key := sym.Ident() + "\x00" + sym.FileName
+		if sym.FileName == "" {
+			declaration, _ := json.Marshal(sym)
+			key = string(declaration)
+		}
+		j, ok := seen[key]
+		if !ok {
+			seen[key] = i
+			continue
+		}
+		first, _ := json.Marshal(b.Symbols[j])
+		second, _ := json.Marshal(sym)
+		return fmt.Errorf("reference data %s lists %s of %s twice, remove one of the entries:\n\t%s/%d: %s\n\t%s/%d: %s",
+			name, sym.Ident(), sym.FileName, root, j, first, root, i, second)
+	}
+	for importPath, pkg := range b.Packages {
+		pkg.prefix = "/packages/" + pointerEscaper.Replace(importPath)
+		if err := pkg.checkDuplicates(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
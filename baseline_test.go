@@ -0,0 +1,100 @@
+package exports
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readBaselineFixture(t *testing.T, name string) (string, []byte) {
+	t.Helper()
+	fileName := filepath.Join("testdata", "baselines", name)
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fileName, data
+}
+
+func TestParseBaselineSchemas(t *testing.T) {
+	tests := []struct {
+		fixture string
+		schema  int
+		bare    bool
+		// generatedAt is the time the snapshot was taken, zero for snapshots without provenance
+		generatedAt time.Time
+	}{
+		{fixture: "schema0.json", schema: 0, bare: true},
+		{fixture: "schema1.json", schema: 1, generatedAt: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{fixture: "schema2.json", schema: 2, generatedAt: time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			fileName, data := readBaselineFixture(t, test.fixture)
+			schema, err := schemaOf(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if schema != test.schema {
+				t.Errorf("schema version is %d, want %d", schema, test.schema)
+			}
+			refData, err := parseBaseline(fileName, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if refData.Schema != baselineSchema {
+				t.Errorf("decoded schema version is %d, want %d", refData.Schema, baselineSchema)
+			}
+			if refData.bare != test.bare {
+				t.Errorf("bare is %v, want %v", refData.bare, test.bare)
+			}
+			switch {
+			case test.generatedAt.IsZero() && refData.Meta != nil:
+				t.Errorf("meta is %v, want none", refData.Meta)
+			case !test.generatedAt.IsZero() && refData.Meta == nil:
+				t.Errorf("meta is missing")
+			case !test.generatedAt.IsZero() && !refData.Meta.GeneratedAt.Equal(test.generatedAt):
+				t.Errorf("generated at %v, want %v", refData.Meta.GeneratedAt, test.generatedAt)
+			}
+
+			// every schema version records the same API
+			idents := make([]string, 0, len(refData.Symbols))
+			for _, sym := range refData.Symbols {
+				idents = append(idents, sym.SymbolType+" "+sym.Ident())
+			}
+			if got, want := strings.Join(idents, ", "), "interface .Plugin, func .GetInfo"; got != want {
+				t.Errorf("symbols are %s, want %s", got, want)
+			}
+			plugin := refData.Symbols[0]
+			if len(plugin.Members) != 1 || plugin.Members[0].Label != "Enable" || plugin.Members[0].FuncSpec == nil || len(plugin.Members[0].FuncSpec.Returns) != 1 {
+				t.Errorf("members of Plugin are %+v, want the method Enable returning error", plugin.Members)
+			}
+		})
+	}
+}
+
+func TestParseBaselineNewerSchema(t *testing.T) {
+	fileName, data := readBaselineFixture(t, "schema99.json")
+	_, err := parseBaseline(fileName, data)
+	if err == nil {
+		t.Fatal("snapshot of a newer schema version was decoded")
+	}
+	if !strings.Contains(err.Error(), "schema version 99, which is newer than this release supports") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParseBaselineDuplicates(t *testing.T) {
+	fileName, data := readBaselineFixture(t, "duplicate.json")
+	_, err := parseBaseline(fileName, data)
+	if err == nil {
+		t.Fatal("snapshot listing a symbol twice was decoded")
+	}
+	for _, want := range []string{"lists .GetInfo of plugin.go twice", "/symbols/0", "/symbols/1"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s: %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package exports
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// blameLines returns the abbreviated commit and author of the newest change to a range of lines in the current tree.
+func blameLines(fileName string, start, end int) (string, error) {
+	out, err := git(filepath.Dir(fileName), "blame", "--porcelain", "-L", fmt.Sprintf("%d,%d", start, end), "--", filepath.Base(fileName))
+	if err != nil {
+		return "", err
+	}
+	authors := make(map[string]string)
+	var commit, newest string
+	var newestTime int64
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasPrefix(line, "\t") || len(fields) == 0:
+		case (len(fields[0]) == 40 || len(fields[0]) == 64) && len(fields) >= 3:
+			commit = fields[0]
+		case fields[0] == "author":
+			authors[commit] = strings.TrimPrefix(line, "author ")
+		case fields[0] == "committer-time" && len(fields) == 2:
+			if t, err := strconv.ParseInt(fields[1], 10, 64); err == nil && t >= newestTime {
+				newest, newestTime = commit, t
+			}
+		}
+	}
+	if strings.Trim(newest, "0") == "" {
+		return "not committed yet", nil
+	}
+	return fmt.Sprintf("%.8s %s", newest, authors[newest]), nil
+}
+
+// blameRemoval guesses the commit removing a symbol as the last one changing
+// the number of occurrences of its name in the file it was declared in.
+func blameRemoval(fileName, label string) (string, error) {
+	if _, err := os.Stat(fileName); err != nil {
+		return "", err
+	}
+	out, err := git(filepath.Dir(fileName), "log", "-1", "--format=%h %an", "-S"+label, "--", filepath.Base(fileName))
+	if err != nil || out == "" {
+		return "", fmt.Errorf("no commit found removing %s", label)
+	}
+	return "likely " + out, nil
+}
+
+// annotateBlame fills Blame for differences that can be traced to a commit,
+// looking each declaration up only once.
+func annotateBlame(diffs []Diff) {
+	cache := make(map[string]string)
+	for i := range diffs {
+		diff := &diffs[i]
+		var key string
+		var lookup func() (string, error)
+		if diff.New != nil && diff.New.FileName != "" && diff.New.Line > 0 {
+			sym := diff.New
+			key = fmt.Sprintf("%s:%d", sym.FileName, sym.Line)
+			lookup = func() (string, error) { return blameLines(sym.FileName, sym.Line, sym.EndLine) }
+		} else if diff.Old != nil && diff.Old.FileName != "" {
+			sym := diff.Old
+			key = sym.FileName + ":" + sym.Label
+			lookup = func() (string, error) { return blameRemoval(sym.FileName, sym.Label) }
+		} else {
+			continue
+		}
+		if _, ok := cache[key]; !ok {
+			res, err := lookup()
+			if err != nil {
+				res = ""
+			}
+			cache[key] = res
+		}
+		diff.Blame = cache[key]
+	}
+}
@@ -0,0 +1,131 @@
+package exports
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// noCache compares again even if nothing changed since the last run, see cachedRun.
+var noCache bool
+
+// cachedRun is the outcome of a comparison, reused as long as neither the sources,
+// the references nor the flags change.
+type cachedRun struct {
+	Code    int    `json:"code"`
+	Verdict string `json:"verdict"`
+	// Report is the text report written to stderr
+	Report string `json:"report"`
+}
+
+// cacheKey hashes everything the outcome of checks depends on: this binary, its
+// arguments and the contents of the files it reads, see watchedFiles. Comparisons that
+// depend on more, like git refs, consumers, -changed-only or the typed backend, which
+// reads imported packages, or that write elsewhere than stderr, are not cached and have
+// no key.
+func cacheKey(checks []packageCheck) string {
+	if noCache || watch || tracing || typed || blame || !inlineText() || len(outputs) > 0 || rewritesFile != "" ||
+		historySpec != "" || len(consumers) > 0 || baselineStore != nil || changedOnly != "" {
+		return ""
+	}
+	hash := sha256.New()
+	executable, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	info, err := os.Stat(executable)
+	if err != nil {
+		return ""
+	}
+	wd, _ := os.Getwd()
+	fmt.Fprintf(hash, "%s %d %d\n%s\n%q\n%+v\n", executable, info.Size(), info.ModTime().UnixNano(), wd, os.Args[1:], checks)
+	if len(policy.FreezeWindows) > 0 {
+		fmt.Fprintln(hash, time.Now().Format("2006-01-02"))
+	}
+	files := watchedFiles(checks)
+	for _, check := range checks {
+		for _, reference := range check.References {
+			fileName, _, _ := splitPackageReference(reference)
+			if info, err := os.Stat(fileName); err != nil || info.IsDir() {
+				// source trees and git refs are not files to hash
+				return ""
+			}
+			files = append(files, fileName, fileName+signatureSuffix)
+		}
+	}
+	if trustedKeys != "" {
+		files = append(files, trustedKeys)
+	}
+	for _, fileName := range files {
+		data, err := ioutil.ReadFile(fileName)
+		if err != nil && !os.IsNotExist(err) {
+			// a file that cannot be read may have changed, so the run cannot be cached
+			return ""
+		}
+		fmt.Fprintf(hash, "%s %d\n", fileName, len(data))
+		hash.Write(data)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// cacheFile is where the run with key is cached, in the user cache directory.
+func cacheFile(key string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "symbol-check", key+".json"), nil
+}
+
+// loadCachedRun returns the cached outcome of the run with key, if any.
+func loadCachedRun(key string) (*cachedRun, bool) {
+	if key == "" {
+		return nil, false
+	}
+	fileName, err := cacheFile(key)
+	if err != nil {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, false
+	}
+	res := new(cachedRun)
+	if err := json.Unmarshal(data, res); err != nil {
+		return nil, false
+	}
+	return res, true
+}
+
+// storeCachedRun caches the outcome of the run with key. Errors only cost the next run
+// a comparison, so they are traced and otherwise ignored.
+func storeCachedRun(key string, run cachedRun) {
+	if key == "" {
+		return
+	}
+	fileName, err := cacheFile(key)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(fileName), 0755)
+	}
+	if err == nil {
+		var data []byte
+		if data, err = json.Marshal(run); err == nil {
+			err = ioutil.WriteFile(fileName, data, 0644)
+		}
+	}
+	if err != nil {
+		tracef("cannot cache the verdict: %v", err)
+	}
+}
+
+// replayCachedRun writes the report of a cached run to w and tells it is reused.
+func replayCachedRun(w io.Writer, run *cachedRun) {
+	io.WriteString(w, run.Report)
+	fmt.Fprintln(w, "nothing changed since the last run, its verdict is reused, compare again with -no-cache")
+}
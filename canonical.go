@@ -0,0 +1,88 @@
+package exports
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// canonical strips positions and the time of snapshots, so that taking one again of an
+// unchanged package gives the same file.
+var canonical bool
+
+// sortSymbols orders symbols by ident, then by file for build variants, as files are
+// read in no particular order. Methods of interfaces are sorted as well, while struct
+// fields and parameters keep their order, which is part of the API.
+func sortSymbols(symbols SymbolList) {
+	sort.SliceStable(symbols, func(i, j int) bool {
+		a, b := symbols[i], symbols[j]
+		if a.Ident() != b.Ident() {
+			return a.Ident() < b.Ident()
+		}
+		if a.FileName != b.FileName {
+			return a.FileName < b.FileName
+		}
+		return a.Pos < b.Pos
+	})
+	for i := range symbols {
+		if symbols[i].SymbolType == "interface" {
+			sortSymbols(symbols[i].Members)
+		}
+	}
+}
+
+// withoutPositions copies symbols leaving out their offsets in their files.
+func withoutPositions(symbols SymbolList) SymbolList {
+	if symbols == nil {
+		return nil
+	}
+	res := make(SymbolList, len(symbols))
+	for i, sym := range symbols {
+		sym.Pos = 0
+		sym.Members = withoutPositions(sym.Members)
+		if sym.FuncSpec != nil {
+			sym.FuncSpec = &FuncSpec{Params: withoutPositions(sym.FuncSpec.Params), Returns: withoutPositions(sym.FuncSpec.Returns)}
+		}
+		if sym.ValueType != nil {
+			sym.ValueType = &withoutPositions(SymbolList{*sym.ValueType})[0]
+		}
+		res[i] = sym
+	}
+	return res
+}
+
+// positionless leaves files and positions out of snapshots and comparisons, so that
+// moving code around changes neither. They are still reported for current symbols.
+var positionless bool
+
+// withoutLocations copies symbols leaving out the files they are declared in and their
+// positions. Build variants declared the same way are then listed once.
+func withoutLocations(symbols SymbolList) SymbolList {
+	res := make(SymbolList, 0, len(symbols))
+	seen := make(map[string]bool)
+	for _, sym := range withoutPositions(symbols) {
+		sym.FileName = ""
+		sym.Members = withoutLocations(sym.Members)
+		key, _ := json.Marshal(sym)
+		if !seen[string(key)] {
+			seen[string(key)] = true
+			res = append(res, sym)
+		}
+	}
+	return res
+}
+
+// canonicalize strips what changes between snapshots of the same API from baseline:
+// positions, the time it was taken at and the commit.
+func canonicalize(baseline *Baseline) {
+	baseline.Symbols = withoutPositions(baseline.Symbols)
+	if positionless {
+		baseline.Symbols = withoutLocations(baseline.Symbols)
+	}
+	for _, pkg := range baseline.Packages {
+		canonicalize(pkg)
+	}
+	if baseline.Meta != nil {
+		baseline.Meta.GeneratedAt, baseline.Meta.Commit = time.Time{}, ""
+	}
+}
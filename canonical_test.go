@@ -0,0 +1,251 @@
+package exports
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/format"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// formattingSource is a package declaring every kind of symbol, formatted with gofmt.
+const formattingSource = `package plugin
+
+import "io"
+
+// Version is the version of the plugin API.
+const Version = "v2"
+
+// Plugin is implemented by plugins.
+type Plugin interface {
+	// Enable starts the plugin.
+	Enable() error
+	Disable() error
+}
+
+// Config configures a plugin.
+type Config struct {
+	Name    string ` + "`json:\"name\"`" + `
+	X, Y    int
+	Output  io.Writer
+	Options map[string][]string
+}
+
+// New returns a plugin.
+//
+// Deprecated: use NewWithConfig.
+func New(name string, size int) (Plugin, error) {
+	return nil, nil
+}
+
+func NewWithConfig(config *Config, opts ...func(*Config)) Plugin {
+	return nil
+}
+
+func (c *Config) Validate() error {
+	return nil
+}
+`
+
+// formattingEdits are edits of formattingSource that only change its layout and comments.
+var formattingEdits = map[string]string{
+	// gofmt reflows this, see TestFormattingLeavesSnapshotsUnchanged
+	"unformatted": `package plugin
+import "io"
+// Version is the version of the plugin API.
+const Version="v2"
+// Plugin is implemented by plugins.
+type Plugin interface{
+// Enable starts the plugin.
+Enable()error
+	  Disable( ) error }
+// Config configures a plugin.
+type Config struct{
+Name string ` + "`json:\"name\"`" + `
+X,Y int
+Output io.Writer
+Options map[ string ][ ]string
+}
+// New returns a plugin.
+//
+// Deprecated: use NewWithConfig.
+func New(name string,
+	size int,
+) (Plugin,
+	error) { return nil, nil }
+func NewWithConfig(config *Config,opts ...func(*Config)) Plugin { return nil }
+func (c *Config) Validate() error { return nil }
+`,
+	"comments": `// Package plugin is the plugin API.
+package plugin
+
+import "io" // writers
+
+/*
+Version is the version of the plugin API, bumped on every major release.
+*/
+const Version = "v2" // keep in sync with go.mod
+
+// Plugin is implemented by plugins. Plugins are loaded by the host.
+type Plugin interface {
+	// Enable starts the plugin, and is called once.
+	Enable() error
+	// Disable stops the plugin.
+	Disable() error // may be called before Enable
+}
+
+// Config configures a plugin.
+// TODO: document every field.
+type Config struct {
+	// Name is shown to users.
+	Name    string ` + "`json:\"name\"`" + ` // unique
+	X, Y    int       /* position */
+	Output  io.Writer // where to log
+	Options map[string][]string
+}
+
+// New returns a plugin.
+//
+// Deprecated: use NewWithConfig.
+func New(name /* shown */ string, size int) (Plugin, error) {
+	// not implemented
+	return nil, nil
+}
+
+// NewWithConfig returns a configured plugin.
+func NewWithConfig(config *Config, opts ...func(*Config)) Plugin {
+	return nil
+}
+
+// Validate checks the config.
+func (c *Config) Validate() error {
+	return nil
+}
+`,
+	"blank lines": `package plugin
+
+
+import "io"
+
+
+
+// Version is the version of the plugin API.
+const Version = "v2"
+// Plugin is implemented by plugins.
+type Plugin interface {
+
+	// Enable starts the plugin.
+	Enable() error
+
+	Disable() error
+
+}
+// Config configures a plugin.
+type Config struct {
+
+	Name    string ` + "`json:\"name\"`" + `
+
+	X, Y    int
+	Output  io.Writer
+
+
+	Options map[string][]string
+}
+// New returns a plugin.
+//
+// Deprecated: use NewWithConfig.
+func New(name string, size int) (Plugin, error) {
+
+	return nil, nil
+
+}
+func NewWithConfig(config *Config, opts ...func(*Config)) Plugin {
+	return nil
+}
+
+
+func (c *Config) Validate() error {
+	return nil
+}
+`,
+}
+
+// extractSource extracts the symbols of a package consisting of source, written to dir,
+// the way snapshots record them. The package is always in the same directory, as
+// snapshots record the files symbols are declared in.
+func extractSource(t *testing.T, dir, source string) SymbolList {
+	t.Helper()
+	if err := ioutil.WriteFile(filepath.Join(dir, "plugin.go"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	symbols, err := snapshotSymbols(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return symbols
+}
+
+// canonicalSnapshot takes a canonical snapshot of a package consisting of source.
+func canonicalSnapshot(t *testing.T, dir, source string) []byte {
+	t.Helper()
+	baseline := &Baseline{Schema: baselineSchema, Symbols: extractSource(t, dir, source)}
+	canonicalize(baseline)
+	data, err := json.Marshal(baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestFormattingLeavesSnapshotsUnchanged(t *testing.T) {
+	formatted, err := format.Source([]byte(formattingEdits["unformatted"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	edits := map[string]string{"gofmt": string(formatted)}
+	for name, source := range formattingEdits {
+		edits[name] = source
+	}
+
+	dir := t.TempDir()
+	want := canonicalSnapshot(t, dir, formattingSource)
+	for name, source := range edits {
+		t.Run(name, func(t *testing.T) {
+			if got := canonicalSnapshot(t, dir, source); !bytes.Equal(got, want) {
+				t.Errorf("snapshot changed:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestFormattingLeavesComparisonsUnchanged(t *testing.T) {
+	// positions are kept in snapshots that are not canonical, but never compared
+	dir := t.TempDir()
+	reference := extractSource(t, dir, formattingSource)
+	for name, source := range formattingEdits {
+		t.Run(name, func(t *testing.T) {
+			for _, diff := range compare(reference, extractSource(t, dir, source)) {
+				t.Errorf("%s %s: %s", diff.Kind, diff.Symbol, diff.Message)
+			}
+		})
+	}
+}
+
+func TestDocsOnlyRecordedWhenCaptured(t *testing.T) {
+	undeprecated := strings.Replace(formattingSource, "//\n// Deprecated: use NewWithConfig.\n", "", 1)
+	if undeprecated == formattingSource {
+		t.Fatal("the source has no deprecation to remove")
+	}
+	dir := t.TempDir()
+	if !bytes.Equal(canonicalSnapshot(t, dir, undeprecated), canonicalSnapshot(t, dir, formattingSource)) {
+		t.Error("snapshot taken without -docs changed with a doc comment")
+	}
+
+	captureDocs = true
+	defer func() { captureDocs = false }()
+	if bytes.Equal(canonicalSnapshot(t, dir, undeprecated), canonicalSnapshot(t, dir, formattingSource)) {
+		t.Error("snapshot taken with -docs did not record the deprecation")
+	}
+}
@@ -0,0 +1,70 @@
+package exports
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var changedOnly string
+
+// changedFiles holds the base names of the files in the work dir that were modified,
+// when extraction and comparison are limited to them with -changed-only. It is nil otherwise.
+var changedFiles map[string]bool
+
+// readChangedFiles parses the -changed-only list: comma separated paths, or "-" to
+// read one path per line from stdin like the output of git diff --name-only. Paths may
+// be relative to the repository root, so they are matched against dir by suffix.
+func readChangedFiles(list, dir string) (map[string]bool, error) {
+	paths := make([]string, 0)
+	if list == "-" {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				paths = append(paths, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, path := range strings.Split(list, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				paths = append(paths, path)
+			}
+		}
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]bool)
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		inDir := filepath.Join(absDir, filepath.Base(path))
+		if inDir == path || strings.HasSuffix(inDir, string(filepath.Separator)+path) {
+			res[filepath.Base(path)] = true
+		}
+	}
+	return res, nil
+}
+
+// isChangedFile reports whether a file of the work dir is included with -changed-only.
+func isChangedFile(fileName string) bool {
+	return changedFiles == nil || changedFiles[filepath.Base(fileName)]
+}
+
+// changedSymbols keeps the symbols declared in changed files.
+func changedSymbols(symbols SymbolList) SymbolList {
+	if changedFiles == nil {
+		return symbols
+	}
+	res := make(SymbolList, 0)
+	for _, sym := range symbols {
+		if isChangedFile(sym.FileName) {
+			res = append(res, sym)
+		}
+	}
+	return res
+}
@@ -1,52 +1,285 @@
-/*symbol-check
-
-this program checks for incompatible symbols(extra exported symbols and incompatible type definitions) that might break forward compatibility when built as a plugin.
-
-Discussion at https://github.com/gotify/server/issues/51#issuecomment-452954279
-
-Sample usage:
-$ go run github.com/eternal-flame-AD/go-exports > export_ref_do_not_edit.json # take a snapshot of the current export in every major release
-$ go run github.com/eternal-flame-AD/go-exports -c export_ref_do_not_edit.json # compare current version for incompatible definitions
-*/
-package main
+// Package exports extracts the exported symbols of a Go package and compares them
+// against snapshots taken earlier, to find changes that break consumers.
+//
+// Extract and Compare are the building blocks; Main runs the symbol-check command
+// built on them, see cmd/symbol-check.
+package exports
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"go/ast"
 	"go/parser"
+	"go/printer"
 	"go/token"
-	"io/ioutil"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 var workDir string
-var compareTo string
+var compareTo stringList
 var pkgName string
+var includeUnexported bool
+var rewritesFile string
+var typed bool
+var freeze stringList
+
+// optional lists the symbols to mark optional in the snapshot, see Symbol.Optional
+var optional stringList
+var trustedKeys string
+var reason string
+var blame bool
+var outputFormat string
+var suppressFile string
+var historySpec string
+var project string
+var consumers stringList
+var storeSpec string
+var captureDocs bool
+var packages stringList
+var saveAs string
+var explain bool
+var profileName string
+var contract string
+var frozenOn string
+var watch bool
+var audit bool
+var identity bool
+
+// baselineStore is set when references are kept in a store with -store
+var baselineStore BaselineStore
 
 type SymbolList []Symbol
 
-func compareSymbolList(source, target SymbolList, cmpLabel bool) []string {
-	diffs := make([]string, 0)
+type DiffKind string
+
+const (
+	DiffAdded   DiffKind = "added"
+	DiffRemoved DiffKind = "removed"
+	DiffChanged DiffKind = "changed"
+	// DiffPromoted is a new exported symbol that existed unexported in the reference
+	DiffPromoted DiffKind = "promoted"
+	// DiffRenamed is a type that moved to a new name together with all its methods
+	DiffRenamed DiffKind = "renamed"
+	// DiffHygiene is a new export breaking a convention enabled with -hygiene
+	DiffHygiene DiffKind = "hygiene"
+	// DiffMoved is a symbol declared in another file than in the reference, which is only informational
+	DiffMoved DiffKind = "moved"
+	// DiffInconsistent is a symbol declared differently by files for different build tags
+	DiffInconsistent DiffKind = "inconsistent"
+	// DiffPlatform is an ABI sensitive symbol using types that differ between platforms
+	DiffPlatform DiffKind = "platform"
+)
+
+type Severity string
+
+const (
+	SeverityBreaking Severity = "breaking"
+	// SeverityWarning is a change that keeps most consumers working
+	SeverityWarning Severity = "warning"
+	// SeverityInfo is a change that does not affect compatibility, like a deprecation
+	SeverityInfo Severity = "info"
+)
+
+// Diff is a single difference between two symbol lists. Symbol is the ident
+// of the symbol the difference was found on, Old and New are its reference and
+// current definitions where they exist.
+type Diff struct {
+	Kind     DiffKind
+	Symbol   string
+	Message  string
+	Severity Severity
+	Old      *Symbol
+	New      *Symbol
+	// Blame names the commit that last touched the symbol, see annotateBlame
+	Blame string
+	// Metadata are the annotations of the reference symbol, see annotateFindings
+	Metadata map[string]string
+	// Reference is the snapshot the difference was found against
+	Reference string
+	// Path leads from Symbol to the member or parameter the difference is about
+	Path []string
+	// Category groups differences of the same nature, see explanations
+	Category string
+	// Pointer is the JSON Pointer of the reference entry the difference concerns, like
+	// /symbols/3/members/1. Additions point at the end of the list they are added to.
+	Pointer string
+}
+
+func (d Diff) String() string {
+	switch d.Kind {
+	case DiffAdded:
+		return "extra symbol found: " + d.Message
+	case DiffRemoved:
+		return "missing symbol: " + d.Message
+	default:
+		return strings.Join(append([]string{d.Symbol}, d.Path...), ": ") + ": " + d.Message
+	}
+}
+
+// nest places a difference found in a member or parameter below its parent, under path.
+func nest(d Diff, path ...string) Diff {
+	res := Diff{Kind: DiffChanged, Message: d.Message, Severity: d.Severity, Path: append(path, d.Path...), Category: d.category(), Pointer: d.Pointer}
+	if d.Kind != DiffChanged {
+		res.Message = d.String()
+	}
+	return res
+}
+
+// paramStep names the parameter or result of list a difference is about.
+func paramStep(name string, list SymbolList, d Diff) string {
+	for i := range list {
+		if d.Old == &list[i] {
+			return fmt.Sprintf("%s %d", name, i)
+		}
+	}
+	return name + "s"
+}
+
+// Options are the settings of a comparison.
+type Options struct {
+	// Policy rates the findings, see Policy.
+	Policy Policy
+	// FailFast stops comparing at the first finding failing the comparison.
+	FailFast bool
+	// Positionless leaves out symbols moved to other files, see -no-positions.
+	Positionless bool
+}
+
+// DefaultOptions are the settings of compare without flags.
+func DefaultOptions() Options {
+	return Options{Policy: Policy{
+		MaxNewExports:      -1,
+		InterfaceAdditions: SeverityBreaking,
+		InterfaceRemovals:  SeverityBreaking,
+		OptionsAdditions:   SeverityInfo,
+		MethodAdditions:    SeverityInfo,
+		MaxSurfaceGrowth:   -1,
+		MaxSurfaceShrink:   -1,
+	}}
+}
+
+// Compare finds the differences between a reference and the current symbols of a package,
+// rated by the policy of options. It only reads its arguments, so comparisons with different
+// options can run concurrently.
+func Compare(reference, current SymbolList, options Options) []Diff {
+	return comparer{Options: options}.compare(flattenInterfaces(reference), flattenInterfaces(current))
+}
+
+// comparer compares symbols with the settings of a comparison. The resolver, if not nil,
+// knows the types of the current package, see -typed.
+type comparer struct {
+	Options
+	resolver *typeResolver
+}
+
+// flagComparer compares with the settings of the command line.
+func flagComparer() comparer {
+	return comparer{Options: Options{Policy: policy, FailFast: failFast, Positionless: positionless}, resolver: resolver}
+}
+
+// compare finds the differences between a reference and the current symbols of a
+// package, with the settings of the command line.
+func compare(reference, current SymbolList) []Diff {
+	return flagComparer().compare(reference, current)
+}
+
+func (c comparer) compare(reference, current SymbolList) []Diff {
+	options := optionsStructs(current)
+	var stop func([]Diff) bool
+	if c.FailFast {
+		stop = func(diffs []Diff) bool { return c.Policy.failsFast(options, diffs) }
+	}
+	diffs := c.compareSymbolListUntil(reference, current, true, stop)
+	if stop != nil && stop(diffs) {
+		tracef("stopped at the first failing finding")
+		c.Policy.relaxOptionsFields(options, diffs)
+		c.Policy.relaxMethodAdditions(diffs)
+		return diffs
+	}
+	diffs = append(diffs, comparePromotions(reference, current)...)
+	diffs = compareAvailability(reference, current, diffs)
+	c.Policy.relaxOptionsFields(options, diffs)
+	c.Policy.relaxMethodAdditions(diffs)
+	return c.correlateTypeRenames(reference, current, diffs)
+}
+
+// sameSymbol reports whether a and b are declared alike.
+func (c comparer) sameSymbol(a, b Symbol) bool {
+	return len(c.compareSymbol(a, b, true)) == 0
+}
+
+func (c comparer) compareSymbolList(source, target SymbolList, cmpLabel bool) []Diff {
+	return c.compareSymbolListUntil(source, target, cmpLabel, nil)
+}
+
+// compareSymbolListUntil compares symbol lists like compareSymbolList, but returns the
+// differences found so far as soon as stop, if not nil, holds for those of a symbol.
+func (c comparer) compareSymbolListUntil(source, target SymbolList, cmpLabel bool, stop func([]Diff) bool) []Diff {
+	diffs := make([]Diff, 0)
 
-	agg := make(map[string]*Symbol)
-	for _, symbol := range source {
-		sym := symbol
-		agg[symbol.Ident()] = &sym
+	// symbols sharing an ident, like parameters of unnamed types, are matched in order
+	agg := make(map[string][]int)
+	for i, symbol := range source {
+		agg[symbol.Ident()] = append(agg[symbol.Ident()], i)
 	}
-	for _, symbol := range target {
-		if origSymbol, ok := agg[symbol.Ident()]; ok {
-			agg[symbol.Ident()] = nil
-			diffs = append(diffs, compareSymbol(*origSymbol, symbol, cmpLabel)...)
+	matched := make([]bool, len(source))
+	// snapshots without files list build variants declared the same way once
+	variants := make(map[string]int)
+	checked := 0
+	for i := range target {
+		if stop != nil && stop(diffs[checked:]) {
+			return diffs
+		}
+		checked = len(diffs)
+		symbol := &target[i]
+		candidates := agg[symbol.Ident()]
+		if j, ok := variants[symbol.Ident()]; ok && len(candidates) == 0 && symbol.FileName != "" {
+			candidates = []int{j}
+		}
+		if len(candidates) > 0 {
+			origSymbol := &source[candidates[0]]
+			agg[symbol.Ident()] = candidates[1:]
+			if origSymbol.FileName == "" && symbol.FileName != "" {
+				variants[symbol.Ident()] = candidates[0]
+			}
+			matched[candidates[0]] = true
+			if symbol.Unexported {
+				continue
+			}
+			pointer := fmt.Sprintf("/%d", candidates[0])
+			for _, diff := range c.compareSymbol(*origSymbol, *symbol, cmpLabel) {
+				diff.Symbol, diff.Old, diff.New = symbol.Ident(), origSymbol, symbol
+				diff.Pointer = pointer + diff.Pointer
+				diffs = append(diffs, diff)
+			}
+			// only top level symbols know their file
+			if !c.Positionless && origSymbol.FileName != "" && symbol.FileName != "" && filepath.Base(origSymbol.FileName) != filepath.Base(symbol.FileName) {
+				diffs = append(diffs, Diff{Kind: DiffMoved, Symbol: symbol.Ident(), Message: fmt.Sprintf("moved from %s to %s", filepath.Base(origSymbol.FileName), filepath.Base(symbol.FileName)), Severity: SeverityInfo, Old: origSymbol, New: symbol, Pointer: pointer})
+			}
+		} else if symbol.Unexported {
+			continue
+		} else if candidates := agg[symbol.unexportedIdent()]; len(candidates) > 0 && source[candidates[0]].Unexported {
+			tracef("%s matches unexported %s of the reference", traceName(symbol), traceName(&source[candidates[0]]))
+			origSymbol := &source[candidates[0]]
+			diffs = append(diffs, Diff{Kind: DiffPromoted, Symbol: symbol.Ident(), Message: fmt.Sprintf("%s, previously %s", symbol, origSymbol), Severity: SeverityBreaking, Old: origSymbol, New: symbol, Pointer: fmt.Sprintf("/%d", candidates[0])})
 		} else {
-			diffs = append(diffs, fmt.Sprintf("extra symbol found: %s", symbol))
+			tracef("%s matches no symbol of the reference", traceName(symbol))
+			diffs = append(diffs, Diff{Kind: DiffAdded, Symbol: symbol.Ident(), Message: symbol.String(), Severity: SeverityBreaking, New: symbol, Pointer: "/-"})
 		}
 	}
-	for _, symbol := range agg {
-		if symbol != nil {
-			diffs = append(diffs, fmt.Sprintf("missing symbol: %s", symbol))
+	for i := range source {
+		if symbol := &source[i]; !matched[i] && !symbol.Unexported {
+			tracef("%s of the reference matches no current symbol", traceName(symbol))
+			diffs = append(diffs, Diff{Kind: DiffRemoved, Symbol: symbol.Ident(), Message: symbol.String(), Severity: SeverityBreaking, Old: symbol, Pointer: fmt.Sprintf("/%d", i)})
 		}
 	}
 
@@ -54,20 +287,79 @@ func compareSymbolList(source, target SymbolList, cmpLabel bool) []string {
 }
 
 type Symbol struct {
-	Label          string     `json:"label,omitempty"`
-	SymbolType     string     `json:"type"`
-	UnderlyingType string     `json:"underlyingType,omitempty"`
-	ReceiverType   string     `json:"receiverType,omitempty"`
-	FileName       string     `json:"fileName,omitempty"`
-	Pos            token.Pos  `json:"pos,omitempty"`
-	Members        SymbolList `json:"members,omitempty"`
-	FuncSpec       *FuncSpec  `json:"funcSpec,omitempty"`
+	Label      string `json:"label,omitempty"`
+	SymbolType string `json:"type"`
+	Unexported bool   `json:"unexported,omitempty"`
+	Frozen     bool   `json:"frozen,omitempty"`
+	// Optional marks symbols a package implementing the API may leave out, see -optional
+	Optional bool `json:"optional,omitempty"`
+	// Metadata are annotations of the symbol, like its owner, set with -meta and
+	// carried into findings about it
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Opaque marks methods of unexported types returned by exported functions, see
+	// typeResolver.opaqueTypes. Those without a file are promoted through embedded fields.
+	Opaque bool `json:"opaque,omitempty"`
+	// ABISensitive marks symbols whose declarations must be the same on every platform, see -abi-sensitive
+	ABISensitive   bool   `json:"abiSensitive,omitempty"`
+	UnderlyingType string `json:"underlyingType,omitempty"`
+	ReceiverType   string `json:"receiverType,omitempty"`
+	// PointerReceiver is set for methods declared on a pointer receiver, like (s *Server)
+	PointerReceiver bool       `json:"pointerReceiver,omitempty"`
+	PkgPath         string     `json:"pkgPath,omitempty"`
+	FileName        string     `json:"fileName,omitempty"`
+	Pos             token.Pos  `json:"pos,omitempty"`
+	Members         SymbolList `json:"members,omitempty"`
+	FuncSpec        *FuncSpec  `json:"funcSpec,omitempty"`
+	Deprecated      string     `json:"deprecated,omitempty"`
+	// Embedded marks struct fields declared without a name, whose fields and methods are promoted
+	Embedded bool `json:"embedded,omitempty"`
+	// ValueType is the declared type of a var or const, or its inferred type with -typed
+	ValueType *Symbol `json:"valueType,omitempty"`
+	// Value is the exact value of a const, only recorded with -const-values
+	Value string `json:"value,omitempty"`
+	// TypeParams are the constraints of the type parameters of a generic type or function, in order.
+	// Type parameters are renamed by position, see typeParamName.
+	TypeParams []string `json:"typeParams,omitempty"`
+	// Imports maps the package names a composite type expression, like map[string]*pkg.Thing,
+	// qualifies types with to their import paths. Simple qualified types use PkgPath.
+	Imports map[string]string `json:"imports,omitempty"`
+	// Tag is the tag of a struct field, unquoted
+	Tag string `json:"tag,omitempty"`
+	// ZeroUnsafe is the field making the zero value of a struct unusable, like a map
+	// only a constructor sets. It is only recorded with -zero-values.
+	ZeroUnsafe string `json:"zeroUnsafe,omitempty"`
+	// ImplementedBy is who implements an interface, consumers or the package itself, as
+	// annotated with a //symbol-check:implemented-by directive
+	ImplementedBy string `json:"implementedBy,omitempty"`
+	// Constraint is the //go:build expression of the file a top level symbol is declared in
+	Constraint string `json:"constraint,omitempty"`
+	// Resolved is the type a type expression denotes, with aliases resolved and packages
+	// named by import path. It is only recorded with -typed, see typeResolver.canonicalType.
+	Resolved string `json:"resolved,omitempty"`
+
+	// Line and EndLine are only known for symbols extracted from source, they are not part of snapshots
+	Line    int `json:"-"`
+	EndLine int `json:"-"`
+	// Grouped marks parameters, results and struct fields declared together with the
+	// previous one, like b in a, b int, see withoutGroupedParams and withoutGroupedFields
+	Grouped bool `json:"-"`
+	// Documented reports whether the declaration has a doc comment
+	Documented bool `json:"-"`
 }
 
 func (c Symbol) Ident() string {
 	return fmt.Sprintf("%s.%s", c.ReceiverType, c.Label)
 }
 
+// unexportedIdent is the ident the symbol would have had before being exported.
+func (c Symbol) unexportedIdent() string {
+	label := []rune(c.Label)
+	if len(label) > 0 {
+		label[0] = unicode.ToLower(label[0])
+	}
+	return fmt.Sprintf("%s.%s", c.ReceiverType, string(label))
+}
+
 func (c Symbol) String() string {
 	res := c.Ident()
 	if c.FileName != "" && c.Pos != 0 {
@@ -76,26 +368,129 @@ func (c Symbol) String() string {
 	return res
 }
 
-func compareSymbol(a, b Symbol, cmpLabel bool) []string {
-	diffs := make([]string, 0)
+// changed is a breaking change of the given category found while comparing a symbol.
+// The caller fills in which symbol it belongs to.
+func changed(category, format string, a ...interface{}) Diff {
+	return Diff{Kind: DiffChanged, Message: fmt.Sprintf(format, a...), Severity: SeverityBreaking, Category: category}
+}
+
+func (c comparer) compareSymbol(a, b Symbol, cmpLabel bool) []Diff {
+	diffs := make([]Diff, 0)
+	// types resolved with -typed are compared by what they denote rather than how they are spelled
+	resolved := a.Resolved != "" && b.Resolved != ""
+	if resolved && a.SymbolType != "member" && a.SymbolType != "alias" {
+		if a.Resolved != b.Resolved {
+			diffs = append(diffs, changed("type", "type changed from %s to %s", a.Resolved, b.Resolved))
+		}
+		traceSymbol(a, b, diffs)
+		return diffs
+	}
 
-	if a.SymbolType != b.SymbolType {
-		diffs = append(diffs, fmt.Sprintf("%s and %s have different symbol types: %s and %s", a, b, a.SymbolType, b.SymbolType))
+	switch {
+	case a.SymbolType == "embed" && b.SymbolType == "member":
+		diffs = append(diffs, changed("embedding", "field %s is no longer embedded, selectors through its promoted fields and methods break", b.Label))
+	case a.SymbolType == "member" && b.SymbolType == "embed":
+		diffs = append(diffs, Diff{Kind: DiffChanged, Message: fmt.Sprintf("field %s is now embedded, its promoted fields and methods may make selectors ambiguous", b.Label), Severity: SeverityWarning, Category: "embedding"})
+	case a.SymbolType == "embed" && b.SymbolType == "embed" && embeddedType(a) != embeddedType(b):
+		diffs = append(diffs, changed("embedding", "embedded field %s changed type from %s to %s", b.Label, embeddedType(a), embeddedType(b)))
+	case a.SymbolType != b.SymbolType:
+		diffs = append(diffs, changed("kind", "%s and %s have different symbol types: %s and %s", a, b, a.SymbolType, b.SymbolType))
 	}
 	if cmpLabel && a.Label != b.Label {
-		diffs = append(diffs, fmt.Sprintf("%s and %s have different labels: %s and %s", a, b, a.Label, b.Label))
+		diffs = append(diffs, changed("kind", "%s and %s have different labels: %s and %s", a, b, a.Label, b.Label))
 
 	}
 	if a.SymbolType == "type" && a.UnderlyingType != b.UnderlyingType {
-		diffs = append(diffs, fmt.Sprintf("type alias %s and %s have different underlying types: %s and %s", a, b, a.UnderlyingType, b.UnderlyingType))
+		diffs = append(diffs, changed("type", "type alias %s and %s have different underlying types: %s and %s", a, b, a.UnderlyingType, b.UnderlyingType))
+	}
+	// references taken before field types were recorded only have their names
+	if a.SymbolType == "member" && b.SymbolType == "member" {
+		switch {
+		case resolved && a.Resolved != b.Resolved:
+			diffs = append(diffs, changed("type", "field type changed from %s to %s", a.Resolved, b.Resolved))
+		case !resolved && a.UnderlyingType != "" && b.UnderlyingType != "" && a.UnderlyingType != b.UnderlyingType:
+			diffs = append(diffs, changed("type", "field type changed from %s to %s", a.UnderlyingType, b.UnderlyingType))
+		}
+	}
+	if a.ZeroUnsafe == "" && b.ZeroUnsafe != "" {
+		diffs = append(diffs, Diff{Kind: DiffChanged, Message: fmt.Sprintf("zero value is no longer usable, field %s must be initialized", b.ZeroUnsafe), Severity: SeverityInfo, Category: "zero-value"})
+	}
+	if c.Policy.FieldTags && a.Tag != b.Tag {
+		diffs = append(diffs, changed("tag", "field tag changed from `%s` to `%s`", a.Tag, b.Tag))
+	}
+	if resolved && a.SymbolType == "alias" && b.SymbolType == "alias" {
+		if a.Resolved != b.Resolved {
+			diffs = append(diffs, changed("alias", "alias of %s is now an alias of %s", a.Resolved, b.Resolved))
+		}
+	} else if a.SymbolType == "alias" && b.SymbolType == "alias" && aliasTarget(a) != aliasTarget(b) {
+		diffs = append(diffs, changed("alias", "alias of %s is now an alias of %s", aliasTarget(a), aliasTarget(b)))
+	}
+	if a.Deprecated == "" && b.Deprecated != "" {
+		diffs = append(diffs, Diff{Kind: DiffChanged, Message: "deprecated: " + b.Deprecated, Severity: SeverityInfo, Category: "deprecated"})
+	} else if a.Deprecated != "" && b.Deprecated == "" {
+		diffs = append(diffs, Diff{Kind: DiffChanged, Message: "no longer deprecated", Severity: SeverityInfo, Category: "undeprecated"})
+	}
+	// values recorded without a type, like untyped constants, cannot be compared
+	if a.ValueType != nil && b.ValueType != nil && !c.sameSymbol(*a.ValueType, *b.ValueType) {
+		diffs = append(diffs, changed("type", "%s and %s have different types: %s and %s", a, b, typeExpr(*a.ValueType), typeExpr(*b.ValueType)))
+	}
+	if a.Value != "" && b.Value != "" && a.Value != b.Value {
+		diffs = append(diffs, Diff{Kind: DiffChanged, Message: fmt.Sprintf("value changed from %s to %s", a.Value, b.Value), Severity: SeverityWarning, Category: "value"})
+	}
+	if len(a.TypeParams) != len(b.TypeParams) {
+		diffs = append(diffs, changed("typeParams", "number of type parameters changed from %d to %d", len(a.TypeParams), len(b.TypeParams)))
+	} else {
+		for i := range a.TypeParams {
+			if a.TypeParams[i] != b.TypeParams[i] {
+				diffs = append(diffs, changed("typeParams", "constraint of type parameter %d changed from %s to %s", i, a.TypeParams[i], b.TypeParams[i]))
+			}
+		}
 	}
 	if a.SymbolType == "method" && a.ReceiverType != b.ReceiverType {
-		diffs = append(diffs, fmt.Sprintf("method %s and %s have different receiver types: %s and %s", a, b, a.ReceiverType, b.ReceiverType))
+		diffs = append(diffs, changed("receiver", "method %s and %s have different receiver types: %s and %s", a, b, a.ReceiverType, b.ReceiverType))
+	}
+	switch {
+	case a.SymbolType != "method" || b.SymbolType != "method":
+	case !a.PointerReceiver && b.PointerReceiver:
+		diffs = append(diffs, changed("receiver", "receiver changed from %s to *%s, values of %s no longer have the method and may stop implementing interfaces", b.ReceiverType, b.ReceiverType, b.ReceiverType))
+	case a.PointerReceiver && !b.PointerReceiver:
+		diffs = append(diffs, Diff{Kind: DiffChanged, Message: fmt.Sprintf("receiver changed from *%s to %s, which adds the method to values of %s", b.ReceiverType, b.ReceiverType, b.ReceiverType), Severity: SeverityInfo, Category: "receiver"})
+	}
+	for _, diff := range c.compareSymbolList(a.Members, b.Members, true) {
+		diff.Pointer = "/members" + diff.Pointer
+		if diff.Kind == DiffChanged {
+			diffs = append(diffs, nest(diff, diff.Symbol))
+			continue
+		}
+		member := nest(diff)
+		member.Category = "member-" + string(diff.Kind)
+		if a.SymbolType == "interface" {
+			// callers only break when methods are removed, implementers when methods are added
+			member.Category = "interface-" + string(diff.Kind)
+			switch {
+			case b.ImplementedBy != "":
+				reason, severity := c.Policy.interfaceChange(b.ImplementedBy, diff.Kind)
+				member.Message += reason
+				member.Severity = severity
+			case diff.Kind == DiffAdded:
+				member.Message += ", which breaks implementers"
+				member.Severity = c.Policy.InterfaceAdditions
+			case diff.Kind == DiffRemoved:
+				member.Message += ", which breaks callers"
+				member.Severity = c.Policy.InterfaceRemovals
+			}
+		}
+		// interface methods are removed in stages: deprecated in one snapshot, dropped in a later one
+		if a.SymbolType == "interface" && diff.Kind == DiffRemoved && diff.Old.Deprecated != "" {
+			member.Message += ", deprecated in the reference"
+			member.Severity = SeverityWarning
+		}
+		diffs = append(diffs, member)
 	}
-	diffs = append(diffs, compareSymbolList(a.Members, b.Members, true)...)
-	if a.SymbolType == "func" {
-		diffs = append(diffs, compareFuncSpec(*a.FuncSpec, *b.FuncSpec)...)
+	if a.FuncSpec != nil && b.FuncSpec != nil {
+		diffs = append(diffs, c.compareFuncSpec(*a.FuncSpec, *b.FuncSpec)...)
 	}
+	traceSymbol(a, b, diffs)
 
 	return diffs
 }
@@ -105,19 +500,66 @@ type FuncSpec struct {
 	Returns SymbolList `json:"returns,omitempty"`
 }
 
-func compareFuncSpec(a, b FuncSpec) []string {
-	diffs := make([]string, 0)
-	for _, diff := range compareSymbolList(a.Params, b.Params, false) {
-		diffs = append(diffs, "func param mismatch: "+diff)
+// compareTypeList compares parameters or results, named by kind, by position, so
+// reordering them changes the types at both positions. A change in their number is
+// reported on its own, besides the types changed at the positions both still have.
+func (c comparer) compareTypeList(source, target SymbolList, kind string) []Diff {
+	diffs := make([]Diff, 0)
+	if len(source) != len(target) {
+		diffs = append(diffs, Diff{
+			Kind:     DiffChanged,
+			Message:  fmt.Sprintf("number of %ss changed from %d to %d", kind, len(source), len(target)),
+			Severity: SeverityBreaking,
+			Category: "signature",
+		})
+	}
+	for i := 0; i < len(source) && i < len(target); i++ {
+		old, new := typeExpr(source[i]), typeExpr(target[i])
+		if source[i].Resolved != "" && target[i].Resolved != "" {
+			old, new = source[i].Resolved, target[i].Resolved
+		}
+		changes := c.compareSymbol(source[i], target[i], false)
+		if old != new {
+			// one change names both types, whatever compareSymbol makes of them
+			changes = []Diff{{Kind: DiffChanged, Message: fmt.Sprintf("type changed from %s to %s", old, new), Severity: SeverityBreaking, Category: "type"}}
+		}
+		for _, diff := range changes {
+			diff.Symbol, diff.Old, diff.New = target[i].Ident(), &source[i], &target[i]
+			diff.Pointer = fmt.Sprintf("/%d%s", i, diff.Pointer)
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs
+}
+
+func (c comparer) compareFuncSpec(a, b FuncSpec) []Diff {
+	diffs, oldParams, newParams := variadicChange(a.Params, b.Params)
+	if widenings := c.widenedParams(oldParams, newParams); widenings != nil {
+		diffs = append(diffs, widenings...)
+	} else {
+		for _, diff := range c.compareTypeList(oldParams, newParams, "parameter") {
+			diff.Pointer = "/funcSpec/params" + diff.Pointer
+			param := nest(diff, paramStep("param", a.Params, diff))
+			if diff.Kind != DiffChanged {
+				param.Category = "signature"
+			}
+			diffs = append(diffs, param)
+		}
 	}
-	for _, diff := range compareSymbolList(a.Returns, b.Returns, false) {
-		diffs = append(diffs, "func result mismatch: "+diff)
+	for _, diff := range c.compareTypeList(a.Returns, b.Returns, "result") {
+		diff.Pointer = "/funcSpec/returns" + diff.Pointer
+		result := nest(diff, paramStep("result", a.Returns, diff))
+		if diff.Kind != DiffChanged {
+			result.Category = "signature"
+		}
+		diffs = append(diffs, result)
 	}
 	return diffs
 }
 
 func exitWithStatusString(s string, code int) {
-	fmt.Fprintln(os.Stderr, s)
+	stopProfiling()
+	slog.Log(context.Background(), exitLevel(code), s, "exitCode", code)
 	os.Exit(code)
 }
 
@@ -125,160 +567,845 @@ func exitWithStatusError(err error, code int) {
 	exitWithStatusString(err.Error(), code)
 }
 
-func init() {
-	workDirFlag := flag.String("d", "./", "work dir")
-	compareToFlag := flag.String("c", "", "compare to")
-	pkgNameFlag := flag.String("p", "", "package name - can be omitted if only one package exists")
+// internalError turns a panic into an error, so a bug makes for a clear message and
+// exit code 1 instead of a stack trace. The stack is traced with -vv.
+func internalError(r interface{}) error {
+	if tracing {
+		tracef("%v\n%s", r, debug.Stack())
+	}
+	return fmt.Errorf("internal error: %v, please report it with the output of -vv", r)
+}
+
+func registerFlags() {
+	flag.StringVar(&workDir, "d", "./", "work dir")
+	flag.Var(&compareTo, "c", "compare to, repeat or separate with commas to compare against several references at once")
+	flag.StringVar(&pkgName, "p", "", "package name - can be omitted if only one package exists")
+	flag.BoolVar(&includeUnexported, "all", false, "include unexported symbols, which lets compare tell newly exported identifiers from new code")
+	flag.BoolVar(&typed, "typed", false, "type-check the package, which infers types of vars and lets compare recognize compatible changes like parameters widened to interfaces")
+	flag.StringVar(&outputFormat, "format", "text", "compare output format: text, or codeclimate (GitLab code quality), checkstyle, sarif (GitHub code scanning), json (every finding with both declarations) or summary-json (counts and semver recommendation) reports on stdout")
+	flag.Var(&outputs, "out", "write the report in a format to a destination, like sarif=report.sarif, human=stderr or json=- for stdout; may be repeated, replaces -format")
+	flag.BoolVar(&blame, "blame", false, "annotate differences with the commit and author that last touched the symbol")
+	flag.StringVar(&rewritesFile, "rewrites", "", "write gofmt -r rules migrating consumers across renames and simple signature changes to this file, - for stdout")
+	flag.StringVar(&trustedKeys, "trusted-keys", "", "file of public keys, compare fails unless the reference is signed by one of them")
+	flag.StringVar(&contract, "contract", "", "stamp the snapshot as the contract of a major version, like v2, which compare echoes")
+	flag.StringVar(&frozenOn, "frozen-on", "", "date the -contract was frozen on, like 2024-09-01, defaults to today")
+	flag.BoolVar(&policy.RequireMajorTarget, "require-major-target", false, "only accept suppressions of breaking findings whose reason names the next major version of the contract, like v3")
+	flag.StringVar(&reason, "reason", "", "reason for taking the snapshot, recorded in its metadata")
+	flag.StringVar(&againstRef, "against", "", "compare against the package as of a git ref, like v1.2.0, read from the repository instead of a snapshot. -c takes such references as git:ref")
+	flag.StringVar(&oldTree, "old", "", "compare a source tree, a directory or import path, against this one directly instead of against a snapshot")
+	flag.StringVar(&newTree, "new", "", "source tree, a directory or import path, compared against -old, defaults to the work dir")
+	flag.Var(&abiSensitive, "abi-sensitive", "comma separated symbols, or * for every export, whose declarations must be the same on every platform. Snapshots record the mark, and compare warns when they use platform dependent types")
+	flag.Var(&policy.PlatformTypes, "platform-types", "comma separated platform dependent types -abi-sensitive symbols are checked for, pkg.* for every type of a package (default int,uint,uintptr,syscall.*)")
+	flag.StringVar(&targetOS, "goos", "", "only read the files built for this GOOS, defaults to the host with -goarch or -tags. Without any of them, files of every platform are read")
+	flag.StringVar(&targetArch, "goarch", "", "only read the files built for this GOARCH, defaults to the host with -goos or -tags")
+	flag.Var(&buildTags, "tags", "comma separated build tags the files read are selected with, like -tags of go build")
+	flag.Var(&platforms, "platforms", "comma separated GOOS/GOARCH pairs compare checks symbols are still built on, defaults to the first class and common ports")
+	flag.BoolVar(&recursive, "r", false, "snapshot every package of the module below the work dir into one snapshot keyed by import path, or compare them against one")
+	flag.BoolVar(&identity, "identity", false, "record the package name and import path in the snapshot, compare then fails when the package is renamed or moved")
+	flag.BoolVar(&captureDocs, "docs", false, "record information from doc comments, like Deprecated: markers, in the snapshot. Without it, comment edits never change the snapshot")
+	flag.Var(&freeze, "freeze", "comma separated symbols to mark frozen in the snapshot, any change to them fails compare")
+	flag.Var(&optional, "optional", "comma separated symbols to mark optional in the snapshot, which -reverse counts as capabilities a package may leave out")
+	flag.Var(&symbolMeta, "meta", "annotate the symbols matching a pattern in the snapshot, like Client.*:owner=team-net; may be repeated. Findings about them carry the annotations")
+	flag.Var(&policy.FreezeWindows, "freeze-window", "date ranges like 2024-12-15..2025-01-06, or single days, during which any change of the API fails compare, even an addition")
+	flag.Var(&policy.Unfreeze, "unfreeze", "comma separated frozen symbols whose changes are acknowledged")
+	flag.IntVar(&policy.MaxNewExports, "max-new-exports", -1, "number of new exported symbols allowed without -ack-new-exports, negative to fail on any new symbol")
+	flag.StringVar(&policy.AckNewExports, "ack-new-exports", "", "token acknowledging the reviewed set of new exported symbols")
+	flag.StringVar(&suppressFile, "suppress", "", "file of accepted findings, by fingerprint, rule ID or symbol pattern, one per line, which are left out of compare; defaults to "+defaultSuppressFile+" in the work dir if it exists")
+	flag.BoolVar(&noCache, "no-cache", false, "compare even if nothing changed since the last run, whose verdict is otherwise reused")
+	flag.StringVar(&cpuProfile, "cpuprofile", "", "write a CPU profile to this file")
+	flag.StringVar(&memProfile, "memprofile", "", "write a heap profile to this file on exit")
+	flag.StringVar(&traceFile, "trace", "", "write an execution trace to this file")
+	flag.StringVar(&historySpec, "history", "", "record the outcome of compare in this history storage, like file:history.jsonl, for the serve subcommand")
+	flag.StringVar(&project, "project", "", "project name compare outcomes are recorded under, defaults to the name of the work dir")
+	flag.Var(&consumers, "consumers", "comma separated consumer modules, or directories of them, to estimate which consumers breaking changes affect")
+	flag.StringVar(&changedOnly, "changed-only", "", "limit compare to symbols declared in these comma separated files, - to read them from stdin like git diff --name-only output")
+	flag.StringVar(&storeSpec, "store", "", "keep snapshots in a store: a directory, http(s) URL or s3://bucket/prefix, -c then takes name@version")
+	flag.StringVar(&saveAs, "save", "", "save the snapshot in the -store as name@version instead of printing it")
+	flag.StringVar((*string)(&policy.InterfaceAdditions), "interface-additions", string(SeverityBreaking), "severity of methods added to interfaces, which break implementers: breaking, warning or info")
+	flag.StringVar((*string)(&policy.InterfaceRemovals), "interface-removals", string(SeverityBreaking), "severity of methods removed from interfaces, which break callers: breaking, warning or info")
+	flag.StringVar((*string)(&policy.OptionsAdditions), "options-additions", string(SeverityInfo), "severity of fields added to options structs, FooOptions structs taken as parameters, whose fields are set by name: breaking, warning or info")
+	flag.StringVar((*string)(&policy.MethodAdditions), "method-additions", string(SeverityInfo), "severity of methods added to concrete types, which keep callers compiling: breaking, warning or info")
+	flag.Var(&packages, "package", "compare several packages in parallel, each given as dir[:package]=reference, repeat a package to compare it against several references")
+	flag.StringVar(&profileName, "profile", "", "policy preset: contract, which freezes interfaces and compares struct field types and signatures strictly, library, which accepts additions, plugin, which accepts additions except methods added to interfaces, or internal, which only reports differences")
+	flag.BoolVar(&watch, "watch", false, "keep comparing, again whenever the package, the reference or the -suppress file changes")
+	flag.BoolVar(&failFast, "fail-fast", false, "stop comparing at the first failing finding, for a quick yes or no on large APIs")
+	flag.Float64Var(&policy.MaxSurfaceGrowth, "max-surface-growth", -1, "percent the API, its exported symbols, fields and methods, may grow by since the reference, negative for no limit")
+	flag.Float64Var(&policy.MaxSurfaceShrink, "max-surface-shrink", -1, "percent the API may shrink by since the reference, 0 for an API that may not shrink, negative for no limit")
+	flag.Var(&policy.ExitOn, "exit-on", "comma separated rules, by ID or category like removed,signature, whose findings are the only ones failing compare")
+	flag.Var(&policy.FailOn, "fail-on", "comma separated classes of findings that fail compare: breaking, additive or informational. By default the severity of each finding decides")
+	flag.Var(&policy.Disable, "disable", "comma separated rule IDs whose findings are left out, like SC019,SC021")
+	flag.Var(&policy.EnableOnly, "enable-only", "comma separated rule IDs whose findings are the only ones kept")
+	flag.BoolVar(&positionless, "no-positions", false, "leave files and positions out of the snapshot, implying -canonical, and do not report symbols moved to other files")
+	flag.BoolVar(&reverse, "reverse", false, "list what the package lacks to provide the API of the -c references, like a newer version of an API to implement, instead of what it broke")
+	flag.BoolVar(&canonical, "canonical", false, "leave positions, the time and the commit out of the snapshot, so it only changes with the API")
+	flag.BoolVar(&constValues, "const-values", false, "record the values of constants, and report constants whose value changed")
+	flag.BoolVar(&zeroValues, "zero-values", false, "record whether the zero values of struct types are usable, and report structs gaining fields only a constructor can set")
+	flag.BoolVar(&policy.FieldTags, "field-tags", false, "compare struct field tags, like json:\"name\", which encoders depend on")
+	flag.Var(&tiers, "tier", "stability tier of packages matching a pattern, like example.com/mod/exp/...=alpha: stable, beta, whose breaking findings only warn, or alpha, whose findings are informational; may be repeated, the first match wins")
+	registerLogFlags(flag.CommandLine)
+	flag.BoolVar(&tracing, "vv", false, "trace every pair of symbols compared and the findings of each, to stderr, for bug reports")
+	flag.BoolVar(&audit, "audit", false, "list the files read and skipped, and the declarations left out, with the reason for each, instead of taking a snapshot")
+	flag.BoolVar(&explain, "explain", false, "explain why each category of findings matters and how to avoid it")
+	flag.Var(&policy.Hygiene, "hygiene", "comma separated conventions symbols added since the reference must follow: doc, underscore, stutter, initialism, context")
+}
+
+// Main runs the symbol-check command with the arguments of the process, and exits.
+func Main() {
+	defer func() {
+		if r := recover(); r != nil {
+			exitWithStatusError(internalError(r), 1)
+		}
+	}()
+	// subcommands log plain messages unless they set up logging themselves
+	slog.SetDefault(slog.New(newPlainHandler(os.Stderr, slog.LevelInfo)))
+	registerFlags()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "cross":
+			runCross(os.Args[2:])
+			return
+		case "compare-dirs":
+			runCompareDirs(os.Args[2:])
+			return
+		case "stub":
+			runStub(os.Args[2:])
+			return
+		case "gen-go":
+			runGenGo(os.Args[2:])
+			return
+		case "compile-test":
+			runCompileTest(os.Args[2:])
+			return
+		case "keygen":
+			runKeygen(os.Args[2:])
+			return
+		case "sign":
+			runSign(os.Args[2:])
+			return
+		case "closure":
+			runClosure(os.Args[2:])
+			return
+		case "query":
+			runQuery(os.Args[2:])
+			return
+		case "update":
+			runUpdate(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		}
+	}
 	flag.Parse()
-	workDir = *workDirFlag
-	compareTo = *compareToFlag
-	pkgName = *pkgNameFlag
+	if err := setupLogging(); err != nil {
+		exitWithStatusError(err, 1)
+	}
+	if err := startProfiling(); err != nil {
+		exitWithStatusError(err, 1)
+	}
+	if profileName != "" {
+		if err := policy.applyProfile(profileName); err != nil {
+			exitWithStatusError(err, 1)
+		}
+	}
+	if err := policy.validate(); err != nil {
+		exitWithStatusError(err, 1)
+	}
+	if len(outputs) > 0 && outputFormat != "text" {
+		exitWithStatusString("-format cannot be combined with -out, add -out "+outputFormat+"=- instead", 1)
+	}
+	if err := parseTiers(tiers); err != nil {
+		exitWithStatusError(err, 1)
+	}
+	if err := parseSymbolMeta(symbolMeta); err != nil {
+		exitWithStatusError(err, 1)
+	}
+	if suppressFile == "" {
+		if _, err := os.Stat(filepath.Join(workDir, defaultSuppressFile)); err == nil {
+			suppressFile = filepath.Join(workDir, defaultSuppressFile)
+		}
+	}
+	defer stopProfiling()
+	for _, platform := range platforms {
+		if goos, goarch, ok := strings.Cut(platform, "/"); !ok || goos == "" || goarch == "" {
+			exitWithStatusString("-platforms takes GOOS/GOARCH pairs like linux/amd64, got "+platform, 1)
+		}
+	}
+
+	if oldTree != "" {
+		if err := useTrees(); err != nil {
+			exitWithStatusError(err, 1)
+		}
+	} else if newTree != "" {
+		exitWithStatusString("-new requires -old", 1)
+	}
+	if againstRef != "" {
+		if len(compareTo) > 0 || len(packages) > 0 || recursive || storeSpec != "" {
+			exitWithStatusString("-against cannot be combined with -c, -package, -r or -store", 1)
+		}
+		compareTo = stringList{gitRefPrefix + againstRef}
+	}
+	var err error
+	if reportTo, err = parseOutputs(outputs, outputFormat); err != nil {
+		exitWithStatusError(err, 1)
+	}
+	if typed {
+		if resolver, err = newTypeResolver(workDir, pkgName); err != nil {
+			exitWithStatusError(err, 1)
+		}
+	}
+	if storeSpec != "" {
+		if trustedKeys != "" {
+			exitWithStatusString("-trusted-keys verifies snapshot files and cannot be used with -store", 1)
+		}
+		if baselineStore, err = openBaselineStore(storeSpec); err != nil {
+			exitWithStatusError(err, 1)
+		}
+	} else if saveAs != "" {
+		exitWithStatusString("-save requires -store", 1)
+	}
+	if changedOnly != "" {
+		if compareTo == nil && !audit {
+			exitWithStatusString("-changed-only requires -c or -audit", 1)
+		}
+		if changedFiles, err = readChangedFiles(changedOnly, workDir); err != nil {
+			exitWithStatusError(err, 1)
+		}
+		if len(changedFiles) == 0 {
+			exitWithStatusString("no files of the package changed, symbols are compatible", 0)
+		}
+	}
+	if recursive {
+		if len(packages) > 0 || changedOnly != "" || typed {
+			exitWithStatusString("-r cannot be combined with -package, -changed-only or -typed", 1)
+		}
+		runRecursive()
+		return
+	}
+	if audit {
+		if err := writeAudit(os.Stdout, workDir, pkgName); err != nil {
+			exitWithStatusError(err, 1)
+		}
+		return
+	}
+	if reverse {
+		if len(compareTo) == 0 || len(packages) > 0 || recursive {
+			exitWithStatusString("-reverse requires -c, and cannot be combined with -package or -r", 1)
+		}
+		runReverse(workDir, pkgName, compareTo)
+	}
+	if len(packages) > 0 {
+		if len(compareTo) > 0 || typed || changedOnly != "" {
+			exitWithStatusString("-package cannot be combined with -c, -typed or -changed-only", 1)
+		}
+		checks, err := parsePackageChecks(packages)
+		if err != nil {
+			exitWithStatusError(err, 1)
+		}
+		runChecks(checks, true)
+	} else if len(compareTo) > 0 {
+		runChecks([]packageCheck{{Dir: workDir, PkgName: pkgName, References: compareTo}}, false)
+	} else {
+		exports, err := snapshotSymbols(workDir, pkgName)
+		if err != nil {
+			exitWithStatusError(err, 1)
+		}
+		baseline := &Baseline{Symbols: exports}
+		if identity {
+			if baseline.Package, err = packageIdentity(workDir, pkgName); err != nil {
+				exitWithStatusError(err, 1)
+			}
+		}
+		writeSnapshot(baseline)
+	}
 }
 
-func main() {
-	fset := token.NewFileSet()
-	pkgs, err := parser.ParseDir(fset, workDir, nil, 0)
+// snapshotSymbols extracts the symbols of a package the way snapshots record them.
+func snapshotSymbols(dir, pkgName string) (SymbolList, error) {
+	exports, err := extract(dir, pkgName)
 	if err != nil {
-		exitWithStatusError(err, 1)
+		return nil, err
+	}
+	if !captureDocs {
+		exports = withoutDocs(exports)
+	}
+	printDiffSections(os.Stderr, policy.enabledDiffs(buildVariants(exports)))
+	for i := range exports {
+		exports[i].Frozen = freeze.matches(exports[i])
+		exports[i].Optional = optional.matches(exports[i])
+		exports[i].ABISensitive = abiSensitive.marksABISensitive(exports[i])
+	}
+	annotateSymbols(exports)
+	return exports, nil
+}
+
+// writeSnapshot adds provenance to a snapshot and saves it in the -store, or prints it.
+func writeSnapshot(baseline *Baseline) {
+	baseline.Schema = baselineSchema
+	baseline.Meta = newBaselineMeta(workDir, reason)
+	baseline.Meta.recorded()
+	if contract != "" {
+		if err := baseline.Meta.stamp(contract, frozenOn); err != nil {
+			exitWithStatusError(err, 1)
+		}
+	} else if frozenOn != "" {
+		exitWithStatusString("-frozen-on requires -contract", 1)
+	}
+	if canonical || positionless {
+		canonicalize(baseline)
+	}
+	if saveAs != "" {
+		i := strings.LastIndex(saveAs, "@")
+		if i < 0 {
+			exitWithStatusString("-save takes name@version", 1)
+		}
+		if err := baselineStore.Save(saveAs[:i], saveAs[i+1:], baseline); err != nil {
+			exitWithStatusError(err, 1)
+		}
+		exitWithStatusString("saved "+saveAs, 0)
+	}
+	resultJSON, err := json.Marshal(baseline)
+	if err != nil {
+		panic(err)
 	}
+	fmt.Println(string(resultJSON))
+}
+
+func isSourceFile(info os.FileInfo) bool {
+	return !strings.HasSuffix(info.Name(), "_test.go")
+}
+
+func selectPackage(pkgs map[string]*ast.Package, dir, pkgName string) (*ast.Package, error) {
 	if pkgName == "" {
 		if len(pkgs) == 1 {
 			for pName := range pkgs {
 				pkgName = pName
 			}
 		} else {
-			panic("multiple packages found")
+			return nil, fmt.Errorf("%d packages found in %s, select one with -p", len(pkgs), dir)
 		}
 	}
-	pkg := pkgs[pkgName]
-	files := make([]*ast.File, 0)
-	for _, file := range pkg.Files {
-		files = append(files, file)
+	pkg, ok := pkgs[pkgName]
+	if !ok {
+		return nil, fmt.Errorf("package %s not found in %s", pkgName, dir)
+	}
+	return pkg, nil
+}
+
+// packageName returns the name of the package extracted from dir.
+func packageName(dir, pkgName string) (string, error) {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, sourceFilter(dir), parser.PackageClauseOnly)
+	if err != nil {
+		return "", err
+	}
+	pkg, err := selectPackage(pkgs, dir, pkgName)
+	if err != nil {
+		return "", err
 	}
+	return pkg.Name, nil
+}
+
+// Extract returns the exported symbols of the package pkgName in dir. The package
+// name can be empty if dir contains a single package.
+func Extract(dir, pkgName string) (SymbolList, error) {
+	return extract(dir, pkgName)
+}
 
+func extract(dir, pkgName string) (SymbolList, error) {
+	fset := token.NewFileSet()
+	// only declarations are needed, identifiers are never resolved to their objects
+	filter := func(info os.FileInfo) bool {
+		return sourceFilter(dir)(info) && isChangedFile(info.Name())
+	}
+	pkgs, err := parser.ParseDir(fset, dir, filter, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) == 0 && changedFiles != nil {
+		// the changed files were all deleted
+		return make(SymbolList, 0), nil
+	}
+	pkg, err := selectPackage(pkgs, dir, pkgName)
+	if err != nil {
+		return nil, err
+	}
+
+	var consts map[string]string
+	if constValues {
+		consts = constantValues(dir, fset, pkg)
+	}
 	exports := make(SymbolList, 0)
+	var interfaces map[string]interfaceDecl
 	for fileName, file := range pkg.Files {
+		imports := fileImports(file)
+		first := len(exports)
 		for _, decl := range file.Decls {
 			switch decl := decl.(type) {
 			case *ast.FuncDecl:
-				if !decl.Name.IsExported() {
+				if !decl.Name.IsExported() && !includeUnexported {
 					break
 				}
 				if decl.Recv == nil {
+					renameTypeParams(decl.Type, declTypeParams(decl.Type.TypeParams))
 					exports = append(exports, Symbol{
 						Label:      decl.Name.Name,
 						SymbolType: "func",
+						Unexported: !decl.Name.IsExported(),
+						Deprecated: deprecation(decl.Doc),
+						Documented: decl.Doc != nil,
 						FileName:   fileName,
 						Pos:        decl.Pos() - file.Pos(),
-						FuncSpec:   funcSpec(decl.Type),
+						Line:       fset.Position(decl.Pos()).Line,
+						EndLine:    fset.Position(decl.End()).Line,
+						FuncSpec:   funcSpec(decl.Type, imports),
+						TypeParams: typeParams(decl.Type.TypeParams),
 					})
 				} else {
+					// the signature refers to type parameters of the receiver by position
+					renameTypeParams(decl.Type, receiverTypeParams(decl))
 					exports = append(exports, Symbol{
-						Label:        decl.Name.Name,
-						SymbolType:   "method",
-						ReceiverType: findReceiver(decl),
-						FileName:     fileName,
-						Pos:          decl.Pos() - file.Pos(),
-						FuncSpec:     funcSpec(decl.Type),
+						Label:           decl.Name.Name,
+						SymbolType:      "method",
+						Unexported:      !decl.Name.IsExported(),
+						Deprecated:      deprecation(decl.Doc),
+						Documented:      decl.Doc != nil,
+						ReceiverType:    findReceiver(decl),
+						PointerReceiver: isPointerReceiver(decl),
+						FileName:        fileName,
+						Pos:             decl.Pos() - file.Pos(),
+						Line:            fset.Position(decl.Pos()).Line,
+						EndLine:         fset.Position(decl.End()).Line,
+						FuncSpec:        funcSpec(decl.Type, imports),
 					})
 				}
+				resolver.annotate(fset, decl.Type, &exports[len(exports)-1])
 			case *ast.GenDecl:
+				var implicitType ast.Expr
 				for _, spec := range decl.Specs {
 					switch spec := spec.(type) {
 					case *ast.TypeSpec:
-						if !ast.IsExported(spec.Name.Name) {
+						if !ast.IsExported(spec.Name.Name) && !includeUnexported {
 							break
 						}
-						res := formatType(spec, file.Pos())
+						params := declTypeParams(spec.TypeParams)
+						renameTypeParams(spec.TypeParams, params)
+						renameTypeParams(spec.Type, params)
+						res := formatType(spec, file.Pos(), imports)
+						switch {
+						case spec.Assign.IsValid():
+							res = aliasType(spec, file.Pos(), imports)
+							resolver.annotate(fset, spec.Type, res)
+						case res.SymbolType == "struct" || res.SymbolType == "interface":
+							resolver.annotate(fset, spec.Type, res)
+						}
+						if typ, ok := spec.Type.(*ast.InterfaceType); ok && !spec.Assign.IsValid() {
+							if interfaces == nil {
+								interfaces = packageInterfaces(pkg)
+							}
+							resolveEmbeds(fset, typ, res, interfaces, map[string]bool{spec.Name.Name: true})
+						}
+						if st, ok := spec.Type.(*ast.StructType); ok && zeroValues {
+							res.ZeroUnsafe = zeroUnsafeField(st)
+						}
+						res.TypeParams = typeParams(spec.TypeParams)
 						res.FileName = fileName
+						res.Line = fset.Position(spec.Pos()).Line
+						res.EndLine = fset.Position(spec.End()).Line
+						res.Unexported = !ast.IsExported(spec.Name.Name)
+						res.Deprecated = deprecation(specDoc(decl, spec.Doc))
+						res.Documented = specDoc(decl, spec.Doc) != nil
+						if res.SymbolType == "interface" {
+							if res.ImplementedBy, err = implementedBy(specDoc(decl, spec.Doc)); err != nil {
+								return nil, fmt.Errorf("%s: %v", fset.Position(spec.Pos()), err)
+							}
+						}
 						exports = append(exports, *res)
 					case *ast.ValueSpec:
-						if !ast.IsExported(spec.Names[0].Name) {
-							break
+						typ := spec.Type
+						if decl.Tok == token.CONST && typ == nil && len(spec.Values) == 0 {
+							// constants without a type or value repeat the ones before them, like iota enums
+							typ = implicitType
+						}
+						implicitType = typ
+						for _, name := range spec.Names {
+							if !name.IsExported() && !includeUnexported {
+								continue
+							}
+							res := Symbol{
+								Label:      name.Name,
+								SymbolType: decl.Tok.String(),
+								Unexported: !name.IsExported(),
+								Deprecated: deprecation(specDoc(decl, spec.Doc), spec.Comment),
+								Documented: specDoc(decl, spec.Doc) != nil,
+								FileName:   fileName,
+								Pos:        name.Pos() - file.Pos(),
+								Line:       fset.Position(name.Pos()).Line,
+								EndLine:    fset.Position(spec.End()).Line,
+								Value:      consts[name.Name],
+							}
+							if typ != nil {
+								res.ValueType = formatType(&ast.TypeSpec{Type: typ}, 0, imports)
+								resolver.annotate(fset, typ, res.ValueType)
+							} else {
+								res.ValueType = resolver.inferredType(dir, name.Name)
+							}
+							exports = append(exports, res)
 						}
-						exports = append(exports, Symbol{
-							Label:      spec.Names[0].Name,
-							SymbolType: "var",
-							FileName:   fileName,
-							Pos:        spec.Pos() - file.Pos(),
-						})
 					}
 				}
 			}
 		}
-	}
-	if compareTo != "" {
-		refDataBytes, err := ioutil.ReadFile(compareTo)
-		if err != nil {
-			panic(err)
-		}
-		refData := new(SymbolList)
-		if err := json.Unmarshal(refDataBytes, refData); err != nil {
-			panic(err)
+		if expr := buildConstraint(file); expr != "" {
+			for i := first; i < len(exports); i++ {
+				exports[i].Constraint = expr
+			}
 		}
-		if diff := compareSymbolList(*refData, exports, true); len(diff) > 0 {
-			fmt.Fprintln(os.Stderr, strings.Join(diff, "\r\n"))
-			exitWithStatusString("symbols are not compatible", 2)
-		} else {
-			exitWithStatusString("symbols are compatible", 0)
+	}
+	exports = resolver.opaqueMethods(dir, exports)
+	sortSymbols(exports)
+	return exports, nil
+}
+
+// withoutDocs copies symbols leaving out everything taken from comments, so that
+// snapshots only change with the declarations themselves.
+func withoutDocs(symbols SymbolList) SymbolList {
+	if symbols == nil {
+		return nil
+	}
+	res := make(SymbolList, len(symbols))
+	for i, sym := range symbols {
+		sym.Deprecated = ""
+		sym.Members = withoutDocs(sym.Members)
+		res[i] = sym
+	}
+	return res
+}
+
+// withoutTags copies symbols leaving out struct field tags, for comparisons against
+// references taken before tags were recorded.
+func withoutTags(symbols SymbolList) SymbolList {
+	if symbols == nil {
+		return nil
+	}
+	res := make(SymbolList, len(symbols))
+	for i, sym := range symbols {
+		sym.Tag = ""
+		sym.Members = withoutTags(sym.Members)
+		res[i] = sym
+	}
+	return res
+}
+
+// withoutPointerReceivers copies symbols leaving out whether methods have pointer
+// receivers, for references which did not record it.
+func withoutPointerReceivers(symbols SymbolList) SymbolList {
+	res := make(SymbolList, len(symbols))
+	for i, sym := range symbols {
+		sym.PointerReceiver = false
+		res[i] = sym
+	}
+	return res
+}
+
+// specDoc is the doc comment of a spec, which is attached to the declaration for ungrouped specs.
+func specDoc(decl *ast.GenDecl, doc *ast.CommentGroup) *ast.CommentGroup {
+	if doc == nil && !decl.Lparen.IsValid() {
+		return decl.Doc
+	}
+	return doc
+}
+
+// deprecation returns the text of the "Deprecated: " paragraph in the given comments, if any.
+func deprecation(groups ...*ast.CommentGroup) string {
+	for _, group := range groups {
+		if group == nil {
+			continue
 		}
-	} else {
-		resultJSON, err := json.Marshal(&exports)
-		if err != nil {
-			panic(err)
+		for _, paragraph := range strings.Split(group.Text(), "\n\n") {
+			if strings.HasPrefix(paragraph, "Deprecated: ") {
+				return strings.Join(strings.Fields(strings.TrimPrefix(paragraph, "Deprecated: ")), " ")
+			}
 		}
-		fmt.Println(string(resultJSON))
 	}
+	return ""
 }
 
 func findReceiver(decl *ast.FuncDecl) string {
 	for _, field := range decl.Recv.List {
-		if typ, ok := field.Type.(*ast.Ident); ok {
+		if typ, ok := receiverBase(field.Type).(*ast.Ident); ok {
 			return typ.Name
 		}
 	}
 	return "unknown"
 }
 
-func funcSpec(decl *ast.FuncType) *FuncSpec {
-	res := FuncSpec{}
+// isPointerReceiver reports whether a method is declared on a pointer receiver.
+func isPointerReceiver(decl *ast.FuncDecl) bool {
+	for _, field := range decl.Recv.List {
+		typ := field.Type
+		for {
+			paren, ok := typ.(*ast.ParenExpr)
+			if !ok {
+				break
+			}
+			typ = paren.X
+		}
+		if _, ok := typ.(*ast.StarExpr); ok {
+			return true
+		}
+	}
+	return false
+}
 
-	if decl.Params != nil {
-		for _, param := range decl.Params.List {
-			//fmt.Printf("%T %s\n", param.Type, formatType(param.Type))
-			typ := &ast.TypeSpec{
-				Type: param.Type,
+// receiverBase strips the pointer, parentheses and the type arguments off a receiver type.
+func receiverBase(expr ast.Expr) ast.Expr {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return receiverBase(expr.X)
+	case *ast.ParenExpr:
+		return receiverBase(expr.X)
+	case *ast.IndexExpr:
+		return expr.X
+	case *ast.IndexListExpr:
+		return expr.X
+	}
+	return expr
+}
+
+// typeParamName is the name type parameters are recorded under, so that renaming
+// them is not a change. It is a valid identifier, which stubs rely on.
+func typeParamName(i int) string {
+	return fmt.Sprintf("_%d", i)
+}
+
+// declTypeParams maps the names of the type parameters a generic type or function
+// declares to their positional names.
+func declTypeParams(list *ast.FieldList) map[string]string {
+	res := make(map[string]string)
+	if list == nil {
+		return res
+	}
+	for _, field := range list.List {
+		for _, name := range field.Names {
+			res[name.Name] = typeParamName(len(res))
+		}
+	}
+	return res
+}
+
+// receiverTypeParams maps the names a method gives to the type parameters of its
+// receiver to their positional names.
+func receiverTypeParams(decl *ast.FuncDecl) map[string]string {
+	res := make(map[string]string)
+	for _, field := range decl.Recv.List {
+		typ := field.Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		var indices []ast.Expr
+		switch typ := typ.(type) {
+		case *ast.IndexExpr:
+			indices = []ast.Expr{typ.Index}
+		case *ast.IndexListExpr:
+			indices = typ.Indices
+		}
+		for i, index := range indices {
+			if ident, ok := index.(*ast.Ident); ok && ident.Name != "_" {
+				res[ident.Name] = typeParamName(i)
 			}
-			res.Params = append(res.Params, *formatType(typ, 0))
 		}
 	}
-	if decl.Results != nil {
-		for _, result := range decl.Results.List {
-			typ := &ast.TypeSpec{
-				Type: result.Type,
+	return res
+}
+
+// renameTypeParams renames the type parameters in params wherever node refers to them
+// as types. Names of fields, parameters and methods are left alone.
+func renameTypeParams(node ast.Node, params map[string]string) {
+	if len(params) == 0 || node == nil {
+		return
+	}
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Field:
+			renameTypeParams(node.Type, params)
+			return false
+		case *ast.SelectorExpr:
+			// pkg.Name never refers to a type parameter
+			return false
+		case *ast.Ident:
+			if name, ok := params[node.Name]; ok {
+				node.Name = name
 			}
-			res.Returns = append(res.Returns, *formatType(typ, 0))
+		}
+		return true
+	})
+}
+
+// typeParams lists the constraints of a type parameter list, one for each parameter.
+func typeParams(list *ast.FieldList) []string {
+	if list == nil {
+		return nil
+	}
+	res := make([]string, 0)
+	for _, field := range list.List {
+		for range field.Names {
+			res = append(res, exprString(field.Type))
+		}
+	}
+	return res
+}
+
+// importScope maps the names packages are imported under in a file to their import paths.
+type importScope map[string]string
+
+func fileImports(file *ast.File) importScope {
+	res := make(importScope)
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		if spec.Name != nil {
+			res[spec.Name.Name] = importPath
+		} else {
+			res[importName(importPath)] = importPath
 		}
 	}
+	return res
+}
 
+// importName guesses the package name of an import path, skipping major version suffixes.
+func importName(importPath string) string {
+	elems := strings.Split(importPath, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = elems[len(elems)-2]
+	}
+	if strings.HasPrefix(name, "go-") {
+		name = name[len("go-"):]
+	}
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// funcSpec records the parameters and results of a func, one entry for each, so a, b int
+// are two parameters.
+func funcSpec(decl *ast.FuncType, imports importScope) *FuncSpec {
+	res := FuncSpec{}
+	fields := func(list *ast.FieldList) SymbolList {
+		if list == nil {
+			return nil
+		}
+		res := make(SymbolList, 0, list.NumFields())
+		for _, field := range list.List {
+			typ := &ast.TypeSpec{
+				Type: field.Type,
+			}
+			for i := 0; i < len(field.Names) || i == 0; i++ {
+				param := *formatType(typ, 0, imports)
+				param.Grouped = i > 0
+				res = append(res, param)
+			}
+		}
+		return res
+	}
+	res.Params = fields(decl.Params)
+	res.Returns = fields(decl.Results)
 	return &res
 }
 
-func formatType(spec *ast.TypeSpec, basePos token.Pos) *Symbol {
+// embeddedType is the type of an embedded field, which is only recorded when it differs from its name.
+func embeddedType(sym Symbol) string {
+	if sym.UnderlyingType != "" {
+		return sym.UnderlyingType
+	}
+	return sym.Label
+}
+
+// embeddedName is the name of an embedded field: its type name without package or pointer.
+func embeddedName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(expr.X)
+	case *ast.SelectorExpr:
+		return expr.Sel.Name
+	case *ast.IndexExpr:
+		return embeddedName(expr.X)
+	case *ast.IndexListExpr:
+		return embeddedName(expr.X)
+	}
+	return exprString(expr)
+}
+
+// exprString renders a type expression in its canonical gofmt form, on a single line.
+func exprString(expr ast.Expr) string {
+	buf := new(bytes.Buffer)
+	if err := printer.Fprint(buf, token.NewFileSet(), expr); err != nil {
+		panic(err)
+	}
+	// fields of inline struct and interface types are joined with semicolons
+	res := ""
+	for _, line := range strings.Split(buf.String(), "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		switch {
+		case res == "":
+			res = line
+		case strings.HasSuffix(res, "{") || strings.HasPrefix(line, "}"):
+			res += " " + line
+		default:
+			res += "; " + line
+		}
+	}
+	return res
+}
+
+// aliasType records an alias declaration, type A = B, by its name and target type.
+// The package a qualified target is imported from is kept, to tell apart targets of
+// the same name in different packages.
+func aliasType(spec *ast.TypeSpec, basePos token.Pos, imports importScope) *Symbol {
+	unnamedParams(spec.Type)
+	res := &Symbol{
+		Label:          spec.Name.Name,
+		SymbolType:     "alias",
+		UnderlyingType: exprString(spec.Type),
+		Pos:            spec.Pos() - basePos,
+	}
+	target := spec.Type
+	if star, ok := target.(*ast.StarExpr); ok {
+		target = star.X
+	}
+	if sel, ok := target.(*ast.SelectorExpr); ok {
+		res.PkgPath = imports[exprString(sel.X)]
+	}
+	return res
+}
+
+// aliasTarget names the target of an alias, with the path of its package if it has one.
+func aliasTarget(sym Symbol) string {
+	if sym.PkgPath != "" && importName(sym.PkgPath) != sym.PkgPath {
+		return fmt.Sprintf("%s (%s)", sym.UnderlyingType, sym.PkgPath)
+	}
+	return sym.UnderlyingType
+}
+
+func formatType(spec *ast.TypeSpec, basePos token.Pos, imports importScope) *Symbol {
 	switch specType := spec.Type.(type) {
 	case *ast.InterfaceType:
 		members := make(SymbolList, 0)
 		for _, methodDecl := range specType.Methods.List {
 			if len(methodDecl.Names) == 0 {
 				members = append(members, Symbol{
-					Label:      methodDecl.Type.(*ast.Ident).String(),
+					Label:      exprString(methodDecl.Type),
 					SymbolType: "embed",
 				})
 			} else {
 				members = append(members, Symbol{
 					Label:      methodDecl.Names[0].Name,
 					SymbolType: "method",
-					FuncSpec:   funcSpec(methodDecl.Type.(*ast.FuncType)),
+					Deprecated: deprecation(methodDecl.Doc, methodDecl.Comment),
+					FuncSpec:   funcSpec(methodDecl.Type.(*ast.FuncType), imports),
 				})
 			}
 		}
@@ -299,15 +1426,31 @@ func formatType(spec *ast.TypeSpec, basePos token.Pos) *Symbol {
 		members := make(SymbolList, 0)
 		for _, methodDecl := range specType.Fields.List {
 			if len(methodDecl.Names) == 0 {
-				members = append(members, Symbol{
-					Label:      methodDecl.Type.(*ast.Ident).String(),
+				member := Symbol{
+					Label:      embeddedName(methodDecl.Type),
 					SymbolType: "embed",
-				})
+					Embedded:   true,
+					Deprecated: deprecation(methodDecl.Doc, methodDecl.Comment),
+				}
+				if _, ok := methodDecl.Type.(*ast.Ident); !ok {
+					member.UnderlyingType = exprString(methodDecl.Type)
+					member.Imports = qualifiedImports(methodDecl.Type, imports)
+				}
+				members = append(members, member)
 			} else {
-				members = append(members, Symbol{
-					Label:      methodDecl.Names[0].Name,
-					SymbolType: "member",
-				})
+				// fields declared together, like X, Y int, are recorded one by one
+				unnamedParams(methodDecl.Type)
+				for i, name := range methodDecl.Names {
+					members = append(members, Symbol{
+						Label:          name.Name,
+						SymbolType:     "member",
+						UnderlyingType: exprString(methodDecl.Type),
+						Imports:        qualifiedImports(methodDecl.Type, imports),
+						Tag:            fieldTag(methodDecl),
+						Deprecated:     deprecation(methodDecl.Doc, methodDecl.Comment),
+						Grouped:        i > 0,
+					})
+				}
 			}
 		}
 		name := ""
@@ -335,43 +1478,107 @@ func formatType(spec *ast.TypeSpec, basePos token.Pos) *Symbol {
 			res.Pos = spec.Pos() - basePos
 		}
 		return res
+	case *ast.ParenExpr:
+		return formatType(&ast.TypeSpec{Name: spec.Name, Type: specType.X}, basePos, imports)
+	}
+
+	// other types are recorded by their expression, with the packages it refers to
+	unnamedParams(spec.Type)
+	res := &Symbol{
+		Label:   exprString(spec.Type),
+		Imports: qualifiedImports(spec.Type, imports),
+	}
+	switch specType := spec.Type.(type) {
 	case *ast.ArrayType:
-		res := &Symbol{
-			Label:      "[]" + fmt.Sprint(specType.Elt),
-			SymbolType: "array",
-		}
-		if basePos != 0 {
-			res.Pos = spec.Pos() - basePos
-		}
-		return res
+		res.SymbolType = "array"
 	case *ast.MapType:
-		res := &Symbol{
-			Label:      fmt.Sprintf("map[%s]%s", specType.Key, specType.Value),
-			SymbolType: "Map",
-		}
-		return res
+		res.SymbolType = "Map"
+	case *ast.ChanType:
+		res.SymbolType = "chan"
+	case *ast.FuncType:
+		res.SymbolType = "funcType"
+	case *ast.Ellipsis:
+		res.SymbolType = "variadic"
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		res.SymbolType = "instance"
 	case *ast.SelectorExpr:
-		res := &Symbol{
-			Label:      fmt.Sprint(specType.X) + "." + specType.Sel.Name,
-			SymbolType: "selector",
-		}
-		if basePos != 0 {
-			res.Pos = spec.Pos() - basePos
-		}
-		return res
+		res.SymbolType = "selector"
+		res.PkgPath, res.Imports = imports[exprString(specType.X)], nil
 	case *ast.StarExpr:
-		res := &Symbol{
-			Label:      "*",
-			SymbolType: "star",
+		res.SymbolType = "star"
+		if x, ok := specType.X.(*ast.SelectorExpr); ok {
+			res.PkgPath, res.Imports = imports[exprString(x.X)], nil
 		}
-		switch x := specType.X.(type) {
-		case *ast.Ident:
-			res.Label += x.Name
-		case *ast.SelectorExpr:
-			res.Label += fmt.Sprintf("%s.%s", x.X, x.Sel.Name)
-		}
-		return res
 	default:
-		panic("unknown type")
+		panic(fmt.Sprintf("unknown type %T", spec.Type))
+	}
+	if spec.Name != nil {
+		// a declared type like type Handlers map[string]Handler
+		res = &Symbol{
+			Label:          spec.Name.Name,
+			SymbolType:     "type",
+			UnderlyingType: res.Label,
+			Imports:        qualifiedImports(spec.Type, imports),
+		}
+	}
+	if basePos != 0 {
+		res.Pos = spec.Pos() - basePos
 	}
+	return res
+}
+
+// fieldTag is the unquoted tag of a struct field, which is the same however it is quoted.
+func fieldTag(field *ast.Field) string {
+	if field.Tag == nil {
+		return ""
+	}
+	tag, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return field.Tag.Value
+	}
+	return tag
+}
+
+// unnamedParams drops the parameter names of the func types in expr, which are not part
+// of their type, so that func(a, b int) and func(x int, y int) are both func(int, int).
+func unnamedParams(expr ast.Expr) {
+	ast.Inspect(expr, func(node ast.Node) bool {
+		funcType, ok := node.(*ast.FuncType)
+		if !ok {
+			return true
+		}
+		for _, list := range []*ast.FieldList{funcType.Params, funcType.Results} {
+			if list == nil {
+				continue
+			}
+			fields := make([]*ast.Field, 0, len(list.List))
+			for _, field := range list.List {
+				for i := 0; i < len(field.Names) || i == 0; i++ {
+					fields = append(fields, &ast.Field{Type: field.Type})
+				}
+			}
+			list.List = fields
+		}
+		return true
+	})
+}
+
+// qualifiedImports maps the package names types in expr are qualified with to their
+// import paths, or returns nil if expr refers to no other package.
+func qualifiedImports(expr ast.Expr, imports importScope) map[string]string {
+	var res map[string]string
+	ast.Inspect(expr, func(node ast.Node) bool {
+		sel, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); ok && imports[x.Name] != "" {
+			if res == nil {
+				res = make(map[string]string)
+			}
+			res[x.Name] = imports[x.Name]
+		}
+		return false
+	})
+	return res
 }
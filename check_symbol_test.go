@@ -0,0 +1,38 @@
+package exports
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCompareOptions(t *testing.T) {
+	dir := t.TempDir()
+	reference := extractSource(t, dir, formattingSource)
+	current := extractSource(t, dir, strings.Replace(formattingSource, "`json:\"name\"`", "`json:\"id\"`", 1))
+
+	tags := DefaultOptions()
+	tags.Policy.FieldTags = true
+	tests := []struct {
+		name    string
+		options Options
+		diffs   int
+	}{
+		{name: "default", options: DefaultOptions(), diffs: 0},
+		{name: "field tags", options: tags, diffs: 1},
+	}
+	// the options of one comparison do not leak into the others
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		for _, test := range tests {
+			wg.Add(1)
+			go func(name string, options Options, want int) {
+				defer wg.Done()
+				if diffs := Compare(reference, current, options); len(diffs) != want {
+					t.Errorf("%s: %d findings, want %d: %v", name, len(diffs), want, diffs)
+				}
+			}(test.name, test.options, test.diffs)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,164 @@
+package exports
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// typeNamePattern finds the type names in labels of composite types like map[string]*Config.
+var typeNamePattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// localTypeNames collects the names in a type expression into refs, leaving out types
+// qualified with a package, which are declared elsewhere.
+func localTypeNames(expr string, refs map[string]bool) {
+	for _, loc := range typeNamePattern.FindAllStringIndex(expr, -1) {
+		qualifier := loc[1] < len(expr) && expr[loc[1]] == '.'
+		// the dots of variadic parameters, ...T, do not qualify
+		qualified := loc[0] > 1 && expr[loc[0]-1] == '.' && expr[loc[0]-2] != '.'
+		if !qualifier && !qualified {
+			refs[expr[loc[0]:loc[1]]] = true
+		}
+	}
+}
+
+func isTypeDecl(sym Symbol) bool {
+	switch sym.SymbolType {
+	case "func", "method", "var", "const":
+		return false
+	}
+	return sym.ReceiverType == ""
+}
+
+// typeRefs collects the names of the types sym refers to directly into refs.
+// Types of other packages are left out, as are the names sym declares itself.
+func typeRefs(sym Symbol, refs map[string]bool) {
+	switch sym.SymbolType {
+	case "type", "alias":
+		localTypeNames(sym.UnderlyingType, refs)
+	case "embed":
+		refs[sym.Label] = true
+	case "star", "array", "Map", "chan", "funcType", "variadic", "instance":
+		localTypeNames(sym.Label, refs)
+	}
+	for _, member := range sym.Members {
+		typeRefs(member, refs)
+	}
+	if sym.FuncSpec != nil {
+		for _, param := range sym.FuncSpec.Params {
+			typeRefs(param, refs)
+		}
+		for _, result := range sym.FuncSpec.Returns {
+			typeRefs(result, refs)
+		}
+	}
+	if sym.ValueType != nil {
+		typeRefs(*sym.ValueType, refs)
+	}
+}
+
+// typeClosures maps every exported symbol to the exported types it depends on, directly
+// or through other types. A type depends on the types used by its methods as well.
+func typeClosures(symbols SymbolList) (map[string][]string, []string) {
+	types := make(map[string]bool)
+	for _, sym := range symbols {
+		if !sym.Unexported && isTypeDecl(sym) {
+			types[sym.Label] = true
+		}
+	}
+	direct := make(map[string]map[string]bool)
+	idents := make([]string, 0)
+	for _, sym := range symbols {
+		if sym.Unexported {
+			continue
+		}
+		refs := make(map[string]bool)
+		typeRefs(sym, refs)
+		owner := sym.Ident()
+		if sym.SymbolType == "method" {
+			// methods are part of their receiver type
+			owner = "." + sym.ReceiverType
+			if direct[owner] == nil {
+				direct[owner] = make(map[string]bool)
+			}
+			for ref := range refs {
+				direct[owner][ref] = true
+			}
+		}
+		if direct[sym.Ident()] == nil {
+			direct[sym.Ident()] = make(map[string]bool)
+		}
+		idents = append(idents, sym.Ident())
+		for ref := range refs {
+			direct[sym.Ident()][ref] = true
+		}
+	}
+
+	res := make(map[string][]string)
+	for _, ident := range idents {
+		seen := map[string]bool{ident: true}
+		queue := []string{ident}
+		closure := make([]string, 0)
+		for len(queue) > 0 {
+			for ref := range direct[queue[0]] {
+				if types[ref] && !seen["."+ref] {
+					seen["."+ref] = true
+					queue = append(queue, "."+ref)
+					closure = append(closure, ref)
+				}
+			}
+			queue = queue[1:]
+		}
+		sort.Strings(closure)
+		res[ident] = closure
+	}
+	sort.Strings(idents)
+	return res, idents
+}
+
+// runClosure reports the exported types each exported symbol depends on, and how
+// many symbols depend on each type, to estimate the impact of changing it.
+func runClosure(args []string) {
+	flags := flag.NewFlagSet("closure", flag.ExitOnError)
+	dir := flags.String("d", "./", "work dir")
+	pkg := flags.String("p", "", "package name - can be omitted if only one package exists")
+	flags.Parse(args)
+
+	symbols, err := extract(*dir, *pkg)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	closures, idents := typeClosures(symbols)
+	dependents := make(map[string]int)
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "symbol\tdepends on")
+	for _, ident := range idents {
+		fmt.Fprintf(table, "%s\t%s\n", ident, strings.Join(closures[ident], ", "))
+		for _, name := range closures[ident] {
+			dependents[name]++
+		}
+	}
+	table.Flush()
+
+	names := make([]string, 0, len(dependents))
+	for name := range dependents {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if dependents[names[i]] != dependents[names[j]] {
+			return dependents[names[i]] > dependents[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	fmt.Println()
+	table = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "type\tdependent symbols")
+	for _, name := range names {
+		fmt.Fprintf(table, "%s\t%d\n", name, dependents[name])
+	}
+	table.Flush()
+}
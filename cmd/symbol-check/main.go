@@ -0,0 +1,19 @@
+/*symbol-check
+
+this program checks for incompatible symbols(extra exported symbols and incompatible type definitions) that might break forward compatibility when built as a plugin.
+
+Discussion at https://github.com/gotify/server/issues/51#issuecomment-452954279
+
+Sample usage:
+$ go run github.com/eternal-flame-AD/go-exports/cmd/symbol-check > export_ref_do_not_edit.json # take a snapshot of the current export in every major release
+$ go run github.com/eternal-flame-AD/go-exports/cmd/symbol-check -c export_ref_do_not_edit.json # compare current version for incompatible definitions
+*/
+package main
+
+import (
+	exports "github.com/eternal-flame-AD/go-exports"
+)
+
+func main() {
+	exports.Main()
+}
@@ -0,0 +1,105 @@
+package exports
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runCompareDirs checks that the package in the second directory is a superset of the
+// one in the first, like api/v2 kept next to api/v1, and optionally writes down how
+// they diverge.
+func runCompareDirs(args []string) {
+	flags := flag.NewFlagSet("compare-dirs", flag.ExitOnError)
+	pkgOld := flags.String("p1", "", "package name in the first directory - can be omitted if only one package exists")
+	pkgNew := flags.String("p2", "", "package name in the second directory - can be omitted if only one package exists")
+	doc := flags.String("doc", "", "write the divergence as a Markdown document to this file, - for stdout")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: symbol-check compare-dirs [flags] old new")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	old, new := flags.Arg(0), flags.Arg(1)
+
+	dirOld, err := resolveSource(old)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	dirNew, err := resolveSource(new)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	symbolsOld, err := extract(dirOld, *pkgOld)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	symbolsNew, err := extract(dirNew, *pkgNew)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+
+	diffs := compare(symbolsOld, symbolsNew)
+	printDiffSections(os.Stderr, diffs)
+	if *doc != "" {
+		if err := writeDivergence(*doc, old, new, diffs); err != nil {
+			exitWithStatusError(err, 1)
+		}
+	}
+	missing := 0
+	for _, diff := range diffs {
+		if diff.Class() == ClassBreaking {
+			missing++
+		}
+	}
+	if missing > 0 {
+		exitWithStatusString(fmt.Sprintf("%s is not a superset of %s, it diverges in %d places", new, old, missing), 2)
+	}
+	exitWithStatusString(fmt.Sprintf("%s is a superset of %s", new, old), 0)
+}
+
+// writeDivergence writes the differences between two versions of a package as a
+// Markdown document, a section for each kind of difference, to keep next to them.
+func writeDivergence(fileName, from, to string, diffs []Diff) error {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "# Divergence of %s from %s\n", to, from)
+	if len(diffs) == 0 {
+		fmt.Fprintf(buf, "\n%s declares the same API as %s.\n", to, from)
+	}
+	for _, kind := range diffSections {
+		title := string(kind)
+		if t, ok := diffSectionTitles[kind]; ok {
+			title = t
+		}
+		section := make([]string, 0)
+		for _, diff := range diffs {
+			if diff.Kind != kind {
+				continue
+			}
+			text := positionPattern.ReplaceAllString(diff.Message, "")
+			if len(diff.Path) > 0 {
+				text = strings.Join(diff.Path, ", ") + ": " + text
+			}
+			// additions and removals are described by the symbol alone
+			if text == diff.Symbol {
+				text = ""
+			} else {
+				text = " " + text
+			}
+			section = append(section, fmt.Sprintf("- `%s`%s (%s)", diff.Symbol, text, diff.Rule()))
+		}
+		if len(section) > 0 {
+			fmt.Fprintf(buf, "\n## %s%s\n\n%s\n", strings.ToUpper(title[:1]), title[1:], strings.Join(section, "\n"))
+		}
+	}
+	if fileName == "-" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(fileName, buf.Bytes(), 0o644)
+}
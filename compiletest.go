@@ -0,0 +1,221 @@
+package exports
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// goPackage is the subset of go list -json output the compile test needs.
+type goPackage struct {
+	ImportPath string
+	Name       string
+	Module     *struct {
+		Path string
+		Dir  string
+	}
+}
+
+func listPackage(dir string) (*goPackage, error) {
+	cmd := exec.Command("go", "list", "-json", ".")
+	cmd.Dir = dir
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	res := new(goPackage)
+	if err := json.Unmarshal(out, res); err != nil {
+		return nil, err
+	}
+	if res.Module == nil {
+		return nil, fmt.Errorf("%s is not part of a module", dir)
+	}
+	return res, nil
+}
+
+// consumerSnippet is a package using the API under test, copied into a scratch module for building.
+type consumerSnippet struct {
+	Name  string
+	Files []string
+}
+
+// findSnippets treats every .go file and every directory in dir as a separate consumer package.
+func findSnippets(dir string) ([]consumerSnippet, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]consumerSnippet, 0)
+	for _, entry := range entries {
+		entryPath := filepath.Join(dir, entry.Name())
+		if !entry.IsDir() {
+			if strings.HasSuffix(entry.Name(), ".go") {
+				res = append(res, consumerSnippet{Name: strings.TrimSuffix(entry.Name(), ".go"), Files: []string{entryPath}})
+			}
+			continue
+		}
+		files, err := filepath.Glob(filepath.Join(entryPath, "*.go"))
+		if err != nil {
+			return nil, err
+		}
+		if len(files) > 0 {
+			res = append(res, consumerSnippet{Name: entry.Name(), Files: files})
+		}
+	}
+	return res, nil
+}
+
+func writeFile(fileName string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, data, 0644)
+}
+
+// setupConsumerModule creates a module containing every snippet, with modPath replaced by modDir.
+func setupConsumerModule(snippets []consumerSnippet, modPath, modDir string) (string, error) {
+	dir, err := ioutil.TempDir("", "symbol-check-consumer")
+	if err != nil {
+		return "", err
+	}
+	goMod := fmt.Sprintf("module symbolcheck.test/consumer\n\ngo 1.22\n\nrequire %s v0.0.0-00010101000000-000000000000\n\nreplace %s => %s\n", modPath, modPath, modDir)
+	if err := writeFile(filepath.Join(dir, "go.mod"), []byte(goMod)); err != nil {
+		return "", err
+	}
+	for _, snippet := range snippets {
+		for _, file := range snippet.Files {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return "", err
+			}
+			if err := writeFile(filepath.Join(dir, snippet.Name, filepath.Base(file)), data); err != nil {
+				return "", err
+			}
+		}
+	}
+	return dir, nil
+}
+
+// buildSnippet returns the compiler output if the snippet does not build.
+func buildSnippet(moduleDir string, snippet consumerSnippet) (string, bool) {
+	cmd := exec.Command("go", "build", "-mod=mod", "-o", os.DevNull, "./"+snippet.Name)
+	cmd.Dir = moduleDir
+	cmd.Env = append(os.Environ(), "GOWORK=off")
+	out, err := cmd.CombinedOutput()
+	return string(out), err == nil
+}
+
+// buildStub returns the compiler output if the stub module in dir does not build.
+func buildStub(dir string) (string, bool) {
+	cmd := exec.Command("go", "build", "-o", os.DevNull, "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off")
+	out, err := cmd.CombinedOutput()
+	return string(out), err == nil
+}
+
+// runCompileTest builds consumer snippets against a stub generated from the reference
+// and against the current tree, reporting snippets broken by the changes.
+func runCompileTest(args []string) {
+	flags := flag.NewFlagSet("compile-test", flag.ExitOnError)
+	dir := flags.String("d", "./", "work dir")
+	reference := flags.String("c", "", "reference snapshot the snippets must compile against")
+	snippetDir := flags.String("snippets", "testdata/consumers", "directory of consumer snippets, each .go file or directory is a separate package")
+	flags.Parse(args)
+	if *reference == "" {
+		exitWithStatusString("compile-test: -c is required", 1)
+	}
+
+	pkg, err := listPackage(*dir)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	refData, err := loadReference(*reference)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	snippets, err := findSnippets(*snippetDir)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	if len(snippets) == 0 {
+		exitWithStatusString(fmt.Sprintf("no consumer snippets found in %s", *snippetDir), 1)
+	}
+
+	// the stub is laid out as a module of the same path, so snippets import it unchanged
+	stubModDir, err := ioutil.TempDir("", "symbol-check-stub")
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	defer os.RemoveAll(stubModDir)
+	stub, err := generateStub(refData.Symbols, pkg.Name, filepath.Base(*reference))
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	stubPkgDir := filepath.Join(stubModDir, strings.TrimPrefix(pkg.ImportPath, pkg.Module.Path))
+	if err := writeFile(filepath.Join(stubPkgDir, "stub.go"), stub); err != nil {
+		exitWithStatusError(err, 1)
+	}
+	if err := writeFile(filepath.Join(stubModDir, "go.mod"), []byte(fmt.Sprintf("module %s\n\ngo 1.22\n", pkg.Module.Path))); err != nil {
+		exitWithStatusError(err, 1)
+	}
+	// snippets cannot prove a break against a stub that does not build
+	if out, ok := buildStub(stubModDir); !ok {
+		exitWithStatusString(fmt.Sprintf("the stub generated from %s does not compile:\n%s", *reference, out), 1)
+	}
+
+	referenceModule, err := setupConsumerModule(snippets, pkg.Module.Path, stubModDir)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	defer os.RemoveAll(referenceModule)
+	currentModule, err := setupConsumerModule(snippets, pkg.Module.Path, pkg.Module.Dir)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	defer os.RemoveAll(currentModule)
+
+	status := func(ok bool) string {
+		if ok {
+			return "ok"
+		}
+		return "FAIL"
+	}
+	table := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "snippet\treference\tcurrent")
+	failures := make([]string, 0)
+	broken, stale := 0, 0
+	for _, snippet := range snippets {
+		refOut, refOk := buildSnippet(referenceModule, snippet)
+		curOut, curOk := buildSnippet(currentModule, snippet)
+		fmt.Fprintf(table, "%s\t%s\t%s\n", snippet.Name, status(refOk), status(curOk))
+		if !refOk {
+			stale++
+			failures = append(failures, fmt.Sprintf("%s does not compile against the reference:\n%s", snippet.Name, refOut))
+		} else if !curOk {
+			broken++
+			failures = append(failures, fmt.Sprintf("%s is broken by the current tree:\n%s", snippet.Name, curOut))
+		}
+	}
+	table.Flush()
+	for _, failure := range failures {
+		fmt.Fprintln(os.Stderr, failure)
+	}
+
+	// snippets not compiling against the reference are out of date, so whether the
+	// current tree breaks them is unknown
+	if stale > 0 {
+		exitWithStatusString(fmt.Sprintf("%d of %d consumer snippets do not compile against the reference, fix them first", stale, len(snippets)), 1)
+	}
+	if broken > 0 {
+		exitWithStatusString(fmt.Sprintf("%d of %d consumer snippets are broken", broken, len(snippets)), 2)
+	}
+	exitWithStatusString("consumer snippets are compatible", 0)
+}
@@ -0,0 +1,68 @@
+package exports
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/importer"
+	"go/token"
+	"go/types"
+)
+
+// constValues records, and compares, the values of exported constants, see constantValues.
+var constValues bool
+
+// constantValues evaluates the constants of a package, by name, reusing the typed
+// backend when it is enabled. Constants whose value cannot be told, like those
+// depending on a package that cannot be imported, are left out.
+func constantValues(dir string, fset *token.FileSet, pkg *ast.Package) map[string]string {
+	scope := (*types.Scope)(nil)
+	if resolver != nil && resolver.dir == dir {
+		scope = resolver.pkg.Scope()
+	} else {
+		files := make([]*ast.File, 0, len(pkg.Files))
+		for _, file := range pkg.Files {
+			files = append(files, file)
+		}
+		conf := types.Config{
+			Importer:         importer.ForCompiler(fset, "source", nil),
+			IgnoreFuncBodies: true,
+			Error:            func(err error) {},
+		}
+		checked, _ := conf.Check(pkg.Name, fset, files, nil)
+		if checked == nil {
+			return nil
+		}
+		scope = checked.Scope()
+	}
+	res := make(map[string]string)
+	for _, name := range scope.Names() {
+		if c, ok := scope.Lookup(name).(*types.Const); ok && c.Val().Kind() != constant.Unknown {
+			res[name] = c.Val().ExactString()
+		}
+	}
+	return res
+}
+
+// withoutConsts copies symbols recording constants as variables, for references
+// taken before the two were told apart.
+func withoutConsts(symbols SymbolList) SymbolList {
+	res := make(SymbolList, len(symbols))
+	for i, sym := range symbols {
+		if sym.SymbolType == "const" {
+			sym.SymbolType = "var"
+		}
+		res[i] = sym
+	}
+	return res
+}
+
+// withoutConstValues copies symbols leaving out the values of constants, for
+// references which did not record them.
+func withoutConstValues(symbols SymbolList) SymbolList {
+	res := make(SymbolList, len(symbols))
+	for i, sym := range symbols {
+		sym.Value = ""
+		res[i] = sym
+	}
+	return res
+}
@@ -0,0 +1,99 @@
+package exports
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const modSourcePrefix = "mod:"
+
+// resolveSource turns a source spec into a local directory. A spec is either
+// a directory or mod:import/path@version, in which case the module containing
+// the package is fetched into the module cache with the go command.
+func resolveSource(spec string) (string, error) {
+	if !strings.HasPrefix(spec, modSourcePrefix) {
+		return spec, nil
+	}
+	pkgPath, version := strings.TrimPrefix(spec, modSourcePrefix), "latest"
+	if i := strings.LastIndex(pkgPath, "@"); i >= 0 {
+		pkgPath, version = pkgPath[:i], pkgPath[i+1:]
+	}
+
+	// the package may live below the module root, so try every prefix of the path
+	var lastErr error
+	for modPath := pkgPath; modPath != "." && modPath != "/"; modPath = path.Dir(modPath) {
+		dir, err := downloadModule(modPath, version)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return filepath.Join(dir, strings.TrimPrefix(pkgPath, modPath)), nil
+	}
+	return "", fmt.Errorf("cannot download %s@%s: %v", pkgPath, version, lastErr)
+}
+
+func downloadModule(modPath, version string) (string, error) {
+	cmd := exec.Command("go", "mod", "download", "-json", modPath+"@"+version)
+	cmd.Dir = os.TempDir()
+	out, err := cmd.Output()
+	res := struct {
+		Dir   string
+		Error string
+	}{}
+	if jsonErr := json.Unmarshal(out, &res); jsonErr != nil {
+		if err != nil {
+			return "", err
+		}
+		return "", jsonErr
+	}
+	if res.Error != "" {
+		return "", fmt.Errorf("%s", res.Error)
+	}
+	if err != nil {
+		return "", err
+	}
+	return res.Dir, nil
+}
+
+// runCross compares the package in -a (usually a fork) against the package in -b (usually upstream).
+func runCross(args []string) {
+	flags := flag.NewFlagSet("cross", flag.ExitOnError)
+	a := flags.String("a", "./", "source of the fork: a directory or mod:import/path@version")
+	b := flags.String("b", "", "source of upstream: a directory or mod:import/path@version")
+	pkgA := flags.String("pa", "", "package name in -a - can be omitted if only one package exists")
+	pkgB := flags.String("pb", "", "package name in -b - can be omitted if only one package exists")
+	flags.Parse(args)
+	if *b == "" {
+		exitWithStatusString("cross: -b is required", 1)
+	}
+
+	dirA, err := resolveSource(*a)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	dirB, err := resolveSource(*b)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	symbolsA, err := extract(dirA, *pkgA)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	symbolsB, err := extract(dirB, *pkgB)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+
+	if diff := compare(symbolsB, symbolsA); len(diff) > 0 {
+		printDiffSections(os.Stderr, diff)
+		exitWithStatusString(fmt.Sprintf("%s diverges from %s in %d places", *a, *b, len(diff)), 2)
+	} else {
+		exitWithStatusString(fmt.Sprintf("%s does not diverge from %s", *a, *b), 0)
+	}
+}
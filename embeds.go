@@ -0,0 +1,173 @@
+package exports
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// interfaceDecl is an interface type declared in the package, with the imports of its file.
+type interfaceDecl struct {
+	typ     *ast.InterfaceType
+	imports importScope
+}
+
+// packageInterfaces lists the interface types declared in pkg, exported or not, by
+// name. Generic interfaces are left out, as their methods depend on type arguments.
+func packageInterfaces(pkg *ast.Package) map[string]interfaceDecl {
+	res := make(map[string]interfaceDecl)
+	for _, file := range pkg.Files {
+		imports := fileImports(file)
+		for _, decl := range file.Decls {
+			decl, ok := decl.(*ast.GenDecl)
+			if !ok || decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				spec := spec.(*ast.TypeSpec)
+				if typ, ok := spec.Type.(*ast.InterfaceType); ok && spec.TypeParams == nil && !spec.Assign.IsValid() {
+					res[spec.Name.Name] = interfaceDecl{typ: typ, imports: imports}
+				}
+			}
+		}
+	}
+	return res
+}
+
+// resolveEmbeds records as the members of every interface embedded in sym, declared by
+// typ, the methods and embeds of that interface, resolved in turn, so interfaces are
+// compared by their method sets, see flattenInterfaces. Interfaces of the package are
+// resolved from their declarations, those of other packages with the typed backend.
+// Embeds that cannot be resolved, like unions or generic interfaces, are left as they are.
+func resolveEmbeds(fset *token.FileSet, typ *ast.InterfaceType, sym *Symbol, interfaces map[string]interfaceDecl, visiting map[string]bool) {
+	for i, field := range typ.Methods.List {
+		if len(field.Names) > 0 || i >= len(sym.Members) {
+			continue
+		}
+		embed := &sym.Members[i]
+		if ident, ok := field.Type.(*ast.Ident); ok {
+			decl, ok := interfaces[ident.Name]
+			if !ok || visiting[ident.Name] {
+				continue
+			}
+			visiting[ident.Name] = true
+			inner := formatType(&ast.TypeSpec{Type: decl.typ}, 0, decl.imports)
+			resolver.annotate(fset, decl.typ, inner)
+			resolveEmbeds(fset, decl.typ, inner, interfaces, visiting)
+			delete(visiting, ident.Name)
+			embed.Members = inner.Members
+			continue
+		}
+		embed.Members = resolver.interfaceMethods(fset, field.Type)
+	}
+}
+
+// interfaceMethods lists the methods of the interface of another package expr denotes,
+// or nothing if its type is unknown or it is a constraint rather than a method set.
+func (r *typeResolver) interfaceMethods(fset *token.FileSet, expr ast.Expr) SymbolList {
+	if r == nil {
+		return nil
+	}
+	typ, ok := r.exprTypes[exprKey(fset, expr)]
+	if !ok {
+		return nil
+	}
+	iface, ok := typ.Underlying().(*types.Interface)
+	if !ok || !iface.IsMethodSet() {
+		return nil
+	}
+	res := make(SymbolList, 0, iface.NumMethods())
+	for i := 0; i < iface.NumMethods(); i++ {
+		method := iface.Method(i)
+		res = append(res, Symbol{Label: method.Name(), SymbolType: "method", FuncSpec: r.funcSpec(method.Type().(*types.Signature))})
+	}
+	return res
+}
+
+// funcSpec records a signature like funcSpec records a func declaration, with the
+// types spelled as the package refers to them and resolved.
+func (r *typeResolver) funcSpec(sig *types.Signature) *FuncSpec {
+	qualifier := func(pkg *types.Package) string {
+		if pkg == r.pkg {
+			return ""
+		}
+		return pkg.Name()
+	}
+	tuple := func(t *types.Tuple, variadic bool) SymbolList {
+		res := make(SymbolList, t.Len())
+		for i := range res {
+			typ := t.At(i).Type()
+			res[i] = Symbol{SymbolType: "type", UnderlyingType: types.TypeString(typ, qualifier), Resolved: r.canonicalType(typ)}
+			if variadic && i == len(res)-1 {
+				elem := typ.(*types.Slice).Elem()
+				res[i] = Symbol{SymbolType: "variadic", Label: "..." + types.TypeString(elem, qualifier), Resolved: "..." + r.canonicalType(elem)}
+			}
+		}
+		return res
+	}
+	return &FuncSpec{Params: tuple(sig.Params(), sig.Variadic()), Returns: tuple(sig.Results(), false)}
+}
+
+// flattenInterfaces copies symbols replacing the embeds of interfaces by the methods
+// they contribute, so moving a method between an interface and one it embeds changes
+// nothing. Embeds recorded without their methods are kept as they are.
+func flattenInterfaces(symbols SymbolList) SymbolList {
+	var flatten func(members SymbolList, seen map[string]bool) SymbolList
+	flatten = func(members SymbolList, seen map[string]bool) SymbolList {
+		res := make(SymbolList, 0, len(members))
+		for _, member := range members {
+			switch {
+			case member.SymbolType == "embed" && len(member.Members) > 0:
+				res = append(res, flatten(member.Members, seen)...)
+			case !seen[member.SymbolType+" "+member.Label]:
+				// interfaces may embed the same method more than once
+				seen[member.SymbolType+" "+member.Label] = true
+				res = append(res, member)
+			}
+		}
+		return res
+	}
+	res := make(SymbolList, len(symbols))
+	for i, sym := range symbols {
+		if sym.SymbolType == "interface" {
+			sym.Members = flatten(sym.Members, make(map[string]bool))
+		}
+		res[i] = sym
+	}
+	return res
+}
+
+// resolvesExternal reports whether symbols record the methods of an interface of
+// another package embedded in one of theirs, as the typed backend does.
+func resolvesExternal(symbols SymbolList) bool {
+	for _, sym := range symbols {
+		if sym.SymbolType == "embed" && strings.Contains(sym.Label, ".") && len(sym.Members) > 0 {
+			return true
+		}
+		if (sym.SymbolType == "interface" || sym.SymbolType == "embed") && resolvesExternal(sym.Members) {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutEmbeddedMethods copies symbols leaving out the members recorded for embedded
+// interfaces, for references taken before they were. With external set, only those of
+// interfaces of other packages are left out, which are only known with the typed backend.
+func withoutEmbeddedMethods(symbols SymbolList, external bool) SymbolList {
+	if symbols == nil {
+		return nil
+	}
+	res := make(SymbolList, len(symbols))
+	for i, sym := range symbols {
+		switch {
+		case sym.SymbolType == "embed" && (!external || strings.Contains(sym.Label, ".")):
+			sym.Members = nil
+		case sym.SymbolType == "interface" || sym.SymbolType == "embed":
+			sym.Members = withoutEmbeddedMethods(sym.Members, external)
+		}
+		res[i] = sym
+	}
+	return res
+}
@@ -0,0 +1,157 @@
+package exports
+
+import (
+	"fmt"
+	"io"
+)
+
+// explanation tells why a category of differences matters and what to do about it.
+type explanation struct {
+	Why    string
+	Remedy string
+}
+
+// explanations are printed with -explain, once for every category found.
+var explanations = map[string]explanation{
+	string(DiffAdded): {
+		Why:    "new exported symbols become part of the API, which has to be supported from now on. Additions are compatible for callers, the strict policy reports them so they are reviewed",
+		Remedy: "keep the symbol unexported until it is ready, or acknowledge the new symbols with -max-new-exports or -ack-new-exports",
+	},
+	"method-added": {
+		Why:    "a method added to a concrete type keeps every caller compiling. It only affects types embedding it next to another type with a method of the same name, and type assertions that now succeed",
+		Remedy: "nothing, or -method-additions breaking for types consumers embed",
+	},
+	string(DiffPromoted): {
+		Why:    "a previously unexported symbol is now part of the API, with whatever behaviour it had as an implementation detail",
+		Remedy: "review it like a new symbol, and acknowledge it with -ack-new-exports",
+	},
+	string(DiffRemoved): {
+		Why:    "code referring to a removed symbol no longer compiles",
+		Remedy: "keep the symbol, mark it with a Deprecated: comment and remove it in the next major version",
+	},
+	string(DiffRenamed): {
+		Why:    "a rename is a removal for every caller still using the old name",
+		Remedy: "keep the old name as an alias, type Old = New, or a wrapper calling the new one, and deprecate it",
+	},
+	"package": {
+		Why:    "consumers import the package by its path and refer to it by its name, so every import or qualified identifier breaks when either changes",
+		Remedy: "keep the package where it is, and have the old path forward to the new one with aliases and wrappers",
+	},
+	"kind": {
+		Why:    "a symbol that changed what it is, like a var becoming a func, is used differently by callers",
+		Remedy: "declare the new kind of symbol under a new name and keep the old one",
+	},
+	"type": {
+		Why:    "values of the old type no longer fit where the new type is expected, so assignments, conversions and arguments break",
+		Remedy: "add a new symbol with the new type and deprecate the old one",
+	},
+	"tag": {
+		Why:    "encoders and decoders like encoding/json read field tags, so values encoded by one version are not decoded by the other",
+		Remedy: "keep the tag, and accept the old name when decoding if the new one is needed",
+	},
+	"constraint": {
+		Why:    "every file is extracted regardless of build constraints, but consumers on the platforms a symbol is no longer built on cannot refer to it",
+		Remedy: "keep a declaration of the symbol for every platform it was available on, returning an error where it is not supported",
+	},
+	"zero-value": {
+		Why:    "callers declaring a value, var t T, or embedding the type rely on its zero value, which now has a field only a constructor sets, so using it panics or blocks",
+		Remedy: "initialize the field lazily where it is used, so the zero value keeps working",
+	},
+	"value": {
+		Why:    "constants are evaluated where they are used, so consumers compiled against the old value, like array lengths or values stored elsewhere, keep it, and a larger value may overflow the types it is assigned to",
+		Remedy: "keep the value, and add a new constant for the new one",
+	},
+	"alias": {
+		Why:    "an alias is the very type it points to, so values, methods and conversions change along with its target",
+		Remedy: "keep the alias pointing to the same type, and add a new alias or type for the new target",
+	},
+	"receiver": {
+		Why:    "method values and method sets depend on the receiver, moving a method changes which types implement interfaces",
+		Remedy: "keep the method on its receiver and add it to the other type as well",
+	},
+	"signature": {
+		Why:    "every call with the old parameters or using the old results stops compiling, as do function values of the old type",
+		Remedy: "add a new function with the new signature, like FooContext or FooWithOptions, and have the old one call it",
+	},
+	"typeParams": {
+		Why:    "instantiations like Box[int] stop compiling when type parameters are added or removed, and type arguments that no longer satisfy a narrowed constraint are rejected",
+		Remedy: "keep the type parameters and their constraints, widening a constraint is fine, and add a new generic type for anything else",
+	},
+	"widened": {
+		Why:    "a parameter widened to an interface still accepts every argument it did, but function values of the old type no longer match",
+		Remedy: "nothing, unless the function is used as a value, like in a callback field",
+	},
+	"member-added": {
+		Why:    "a new struct field breaks composite literals without field names, and can make selectors through embedding ambiguous",
+		Remedy: "document that the struct is to be initialized with field names, or add the field to a new struct embedding the old one",
+	},
+	"member-removed": {
+		Why:    "code reading or setting the field no longer compiles",
+		Remedy: "keep the field, ignore its value if needed and mark it Deprecated:",
+	},
+	"interface-added": {
+		Why:    "every type implementing the interface outside of the package lacks the new method and stops implementing it",
+		Remedy: "add the method to a new extension interface instead, and check for it with a type assertion",
+	},
+	"interface-removed": {
+		Why:    "callers of the removed method through the interface no longer compile",
+		Remedy: "deprecate the method in one release and remove it in a later one, which reports it as a warning",
+	},
+	"embedding": {
+		Why:    "the fields and methods promoted through an embedded field are part of the API of the struct, changing the embedding changes them",
+		Remedy: "keep the embedded field as it is, and add named fields for anything new",
+	},
+	"promotion": {
+		Why:    "fields and methods of embedded types are selected on the outer struct, like cfg.Timeout, and stop compiling once the embedding that promoted them is gone or made them ambiguous",
+		Remedy: "keep embedding the type, or declare the promoted fields and methods on the struct itself",
+	},
+	"deprecated": {
+		Why:    "a deprecation does not break anything, it tells callers to migrate before the symbol goes away",
+		Remedy: "point to the replacement in the Deprecated: comment",
+	},
+	"undeprecated": {
+		Why:    "the symbol is supported again, which is compatible",
+		Remedy: "nothing",
+	},
+	string(DiffInconsistent): {
+		Why:    "a symbol declared with different types or signatures for different platforms or build tags compiles for some consumers and not for others",
+		Remedy: "give every variant the same declaration, and keep platform specific details in unexported code",
+	},
+	string(DiffPlatform): {
+		Why:    "types like int and uintptr change size with the platform, and syscall types are declared per platform, so binaries built elsewhere disagree on the layout of values passed through the symbol",
+		Remedy: "use sized types like int64, and keep platform specific types out of ABI sensitive declarations",
+	},
+	string(DiffMoved): {
+		Why:    "the file a symbol is declared in is not part of the API, moves are listed to keep snapshot positions understandable",
+		Remedy: "nothing",
+	},
+	string(DiffHygiene): {
+		Why:    "new symbols are the cheapest to fix before a release, afterwards renaming them is a breaking change",
+		Remedy: "follow the convention named by the finding, or leave the rule out of -hygiene",
+	},
+}
+
+// category groups differences for explanations, falling back to the kind of difference.
+func (d Diff) category() string {
+	if d.Category != "" {
+		return d.Category
+	}
+	return string(d.Kind)
+}
+
+// printExplanations explains each category of diffs once, in order of first appearance.
+func printExplanations(w io.Writer, diffs []Diff) {
+	seen := make(map[string]bool)
+	for _, diff := range diffs {
+		category := diff.category()
+		e, ok := explanations[category]
+		if !ok || seen[category] {
+			continue
+		}
+		if len(seen) == 0 {
+			fmt.Fprintln(w, "explanations:")
+		}
+		seen[category] = true
+		fmt.Fprintf(w, "\t%s %s: %s\n\t\tremedy: %s\n", diff.Rule(), category, e.Why, e.Remedy)
+	}
+}
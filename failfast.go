@@ -0,0 +1,22 @@
+package exports
+
+// failFast stops comparing at the first finding that fails the comparison, for CI
+// jobs that only need to know whether a large API is compatible.
+var failFast bool
+
+// failsFast reports whether any of diffs fails the comparison once the policy has
+// rated it. Options structs are those of the current symbols, see optionsStructs.
+func (p Policy) failsFast(options map[string]bool, diffs []Diff) bool {
+	if len(diffs) == 0 {
+		return false
+	}
+	rated := append([]Diff(nil), diffs...)
+	p.relaxOptionsFields(options, rated)
+	p.relaxMethodAdditions(rated)
+	for _, diff := range p.enabledDiffs(rated) {
+		if p.fails(diff) && !p.suppressed(diff) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package exports
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitRefPrefix marks references to the package at a git ref, like git:v1.2.0, which
+// are read from the repository instead of a snapshot file.
+const gitRefPrefix = "git:"
+
+// againstRef is the git ref compared against with -against.
+var againstRef string
+
+// loadGitRef extracts the symbols of the package in the work dir as of a git ref. The
+// files are read from the object store, so the working tree is left alone.
+func loadGitRef(ref string) (*Baseline, error) {
+	if _, err := git(workDir, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		return nil, fmt.Errorf("%s is not a commit of the git repository of %s", ref, workDir)
+	}
+	names, err := git(workDir, "ls-tree", "--name-only", ref, "--", ".")
+	if err != nil {
+		return nil, fmt.Errorf("cannot list files at git ref %s: %v", ref, err)
+	}
+	tmp, err := os.MkdirTemp("", "symbol-check-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	found := false
+	for _, name := range strings.Split(names, "\n") {
+		if !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		cmd := exec.Command("git", "show", ref+":./"+name)
+		cmd.Dir = workDir
+		data, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s at git ref %s: %v", name, ref, err)
+		}
+		if err := os.WriteFile(filepath.Join(tmp, name), data, 0o644); err != nil {
+			return nil, err
+		}
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("no Go files in %s at git ref %s", workDir, ref)
+	}
+	res, err := loadTree(tmp)
+	if err != nil {
+		return nil, err
+	}
+	// report the files where they are in the working tree
+	for i := range res.Symbols {
+		if res.Symbols[i].FileName != "" {
+			res.Symbols[i].FileName = filepath.Join(workDir, filepath.Base(res.Symbols[i].FileName))
+		}
+	}
+	return res, nil
+}
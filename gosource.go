@@ -0,0 +1,163 @@
+package exports
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// modulePath is the import path the generated Go source refers to this package by.
+const modulePath = "github.com/eternal-flame-AD/go-exports"
+
+// goLiteral writes v as a Go expression, leaving out zero fields. Types of elements of
+// slices and maps are elided where Go allows it, and time.Time values use time.Date.
+func goLiteral(buf *bytes.Buffer, v reflect.Value, elided bool) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			buf.WriteString("nil")
+			return
+		}
+		if !elided {
+			buf.WriteString("&")
+		}
+		goLiteral(buf, v.Elem(), elided)
+	case reflect.Struct:
+		if t, ok := v.Interface().(time.Time); ok {
+			t = t.UTC()
+			fmt.Fprintf(buf, "time.Date(%d, %d, %d, %d, %d, %d, %d, time.UTC)", t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond())
+			return
+		}
+		if !elided {
+			buf.WriteString(v.Type().String())
+		}
+		buf.WriteString("{\n")
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() || field.Tag.Get("json") == "-" || v.Field(i).IsZero() {
+				continue
+			}
+			fmt.Fprintf(buf, "%s: ", field.Name)
+			goLiteral(buf, v.Field(i), false)
+			buf.WriteString(",\n")
+		}
+		buf.WriteString("}")
+	case reflect.Slice:
+		if v.IsNil() {
+			buf.WriteString("nil")
+			return
+		}
+		buf.WriteString(v.Type().String() + "{\n")
+		for i := 0; i < v.Len(); i++ {
+			goLiteral(buf, v.Index(i), true)
+			buf.WriteString(",\n")
+		}
+		buf.WriteString("}")
+	case reflect.Map:
+		if v.IsNil() {
+			buf.WriteString("nil")
+			return
+		}
+		buf.WriteString(v.Type().String() + "{\n")
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, key := range keys {
+			goLiteral(buf, key, true)
+			buf.WriteString(": ")
+			goLiteral(buf, v.MapIndex(key), true)
+			buf.WriteString(",\n")
+		}
+		buf.WriteString("}")
+	case reflect.String:
+		buf.WriteString(strconv.Quote(v.String()))
+	default:
+		fmt.Fprintf(buf, "%v", v.Interface())
+	}
+}
+
+// generateGoSource renders a snapshot as a Go file declaring it as the variable name,
+// with a go:generate directive running command to generate it again.
+func generateGoSource(refData *Baseline, pkgName, name, source, command string) ([]byte, error) {
+	literal := new(bytes.Buffer)
+	goLiteral(literal, reflect.ValueOf(refData), false)
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "// Code generated by symbol-check gen-go from %s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(buf, "//go:generate %s\n\n", command)
+	fmt.Fprintf(buf, "package %s\n\n", pkgName)
+	fmt.Fprintln(buf, "import (")
+	if strings.Contains(literal.String(), "time.Date(") {
+		fmt.Fprintln(buf, `"time"`)
+		fmt.Fprintln(buf)
+	}
+	fmt.Fprintf(buf, "exports %q\n", modulePath)
+	fmt.Fprintln(buf, ")")
+	fmt.Fprintf(buf, "\n// %s is the API recorded in %s, which exports.Compare checks symbols against.\n", name, source)
+	fmt.Fprintf(buf, "var %s = %s\n", name, literal)
+	return format.Source(buf.Bytes())
+}
+
+// runGenGo writes a snapshot as Go source, so hosts can import the contract and check
+// plugins against it at run time.
+func runGenGo(args []string) {
+	flags := flag.NewFlagSet("gen-go", flag.ExitOnError)
+	reference := flags.String("c", "", "reference snapshot, or package of a module snapshot as file#import/path, to generate Go source from")
+	outFile := flags.String("o", "", "output file, the source is printed if omitted")
+	pkg := flags.String("p", "", "package name of the generated file, defaults to the name of the directory of the output file")
+	name := flags.String("var", "Contract", "name of the variable declaring the snapshot")
+	flags.Parse(args)
+	if *reference == "" {
+		exitWithStatusString("gen-go: -c is required", 1)
+	}
+	outDir := "."
+	if *outFile != "" {
+		outDir = filepath.Dir(*outFile)
+	}
+	if *pkg == "" {
+		if *outFile == "" {
+			exitWithStatusString("gen-go: -p is required when printing the source", 1)
+		}
+		abs, err := filepath.Abs(outDir)
+		if err != nil {
+			exitWithStatusError(err, 1)
+		}
+		*pkg = filepath.Base(abs)
+	}
+	refData, err := loadReference(*reference)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+
+	// go generate runs in the directory of the generated file
+	fileName, importPath, ok := splitPackageReference(*reference)
+	if rel, err := filepath.Rel(outDir, fileName); err == nil {
+		fileName = filepath.ToSlash(rel)
+	}
+	source := filepath.Base(fileName)
+	if ok {
+		fileName += "#" + importPath
+	}
+	command := fmt.Sprintf("go run %s/cmd/symbol-check gen-go -c %s -p %s -var %s", modulePath, fileName, *pkg, *name)
+	if *outFile != "" {
+		command += " -o " + filepath.Base(*outFile)
+	}
+	src, err := generateGoSource(refData, *pkg, *name, source, command)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	if *outFile == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := ioutil.WriteFile(*outFile, src, 0644); err != nil {
+		exitWithStatusError(err, 1)
+	}
+}
@@ -0,0 +1,322 @@
+package exports
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HistoryRecord is the outcome of one comparison, kept to report trends across a project's history.
+type HistoryRecord struct {
+	Project    string    `json:"project"`
+	Time       time.Time `json:"time"`
+	Reference  string    `json:"reference"`
+	Commit     string    `json:"commit,omitempty"`
+	Symbols    int       `json:"symbols"`
+	Breaking   int       `json:"breaking"`
+	Warnings   int       `json:"warnings"`
+	Added      int       `json:"added"`
+	Removed    int       `json:"removed"`
+	Compatible bool      `json:"compatible"`
+}
+
+// HistoryStore persists comparison results. Stores are selected with a
+// scheme:location spec, see RegisterHistoryStore.
+type HistoryStore interface {
+	Append(record HistoryRecord) error
+	// Records returns the records of project, oldest first.
+	Records(project string) ([]HistoryRecord, error)
+	// Projects returns the projects with records, sorted.
+	Projects() ([]string, error)
+	// Close releases the store, it is not used after.
+	Close() error
+}
+
+// historyBackends open a store for each supported scheme. sqlite and postgres need
+// a database/sql driver, which programs running Main link in, see openSQLHistory.
+var historyBackends = map[string]func(location string) (HistoryStore, error){
+	"file": func(location string) (HistoryStore, error) {
+		return &fileHistory{fileName: location}, nil
+	},
+	"sqlite": func(location string) (HistoryStore, error) {
+		return openSQLHistory(sqliteDialect, location)
+	},
+	"postgres": func(location string) (HistoryStore, error) {
+		// URLs like postgres://host/db are passed on whole
+		if strings.HasPrefix(location, "//") {
+			location = "postgres:" + location
+		}
+		return openSQLHistory(postgresDialect, location)
+	},
+}
+
+// RegisterHistoryStore makes -history and serve open specs of scheme, like
+// scheme:location, with open, which is given the location. Programs running Main
+// register their stores before calling it. A scheme registered twice, like sqlite or
+// postgres, is opened by the last store registered for it.
+func RegisterHistoryStore(scheme string, open func(location string) (HistoryStore, error)) {
+	historyBackends[scheme] = open
+}
+
+// openHistory opens a store from a spec like file:history.jsonl. A spec without a scheme is a file.
+func openHistory(spec string) (HistoryStore, error) {
+	scheme, location := "file", spec
+	if i := strings.Index(spec, ":"); i > 1 {
+		scheme, location = spec[:i], spec[i+1:]
+	}
+	open, ok := historyBackends[scheme]
+	if !ok {
+		return nil, fmt.Errorf("history storage %s is not supported by this build", scheme)
+	}
+	return open(location)
+}
+
+// fileHistory keeps records as JSON lines, which is enough for a single server.
+type fileHistory struct {
+	fileName string
+	mu       sync.Mutex
+}
+
+func (h *fileHistory) Append(record HistoryRecord) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(h.fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+func (h *fileHistory) all() ([]HistoryRecord, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	file, err := os.Open(h.fileName)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	res := make([]HistoryRecord, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var record HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("%s: %v", h.fileName, err)
+		}
+		res = append(res, record)
+	}
+	return res, scanner.Err()
+}
+
+func (h *fileHistory) Records(project string) ([]HistoryRecord, error) {
+	records, err := h.all()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]HistoryRecord, 0)
+	for _, record := range records {
+		if record.Project == project {
+			res = append(res, record)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Time.Before(res[j].Time) })
+	return res, nil
+}
+
+func (h *fileHistory) Close() error {
+	return nil
+}
+
+func (h *fileHistory) Projects() ([]string, error) {
+	records, err := h.all()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	res := make([]string, 0)
+	for _, record := range records {
+		if !seen[record.Project] {
+			seen[record.Project] = true
+			res = append(res, record.Project)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// newHistoryRecord summarizes a comparison of the symbols of dir against reference.
+func newHistoryRecord(project, reference, dir string, current SymbolList, diffs []Diff, compatible bool) HistoryRecord {
+	record := HistoryRecord{
+		Project:    project,
+		Time:       time.Now().UTC().Truncate(time.Second),
+		Reference:  reference,
+		Compatible: compatible,
+	}
+	if commit, err := git(dir, "rev-parse", "HEAD"); err == nil {
+		record.Commit = commit
+	}
+	for _, sym := range current {
+		if !sym.Unexported {
+			record.Symbols++
+		}
+	}
+	for _, diff := range diffs {
+		switch {
+		case policy.fails(diff):
+			record.Breaking++
+		case diff.Severity == SeverityWarning:
+			record.Warnings++
+		}
+		switch diff.Kind {
+		case DiffAdded, DiffPromoted:
+			record.Added++
+		case DiffRemoved:
+			record.Removed++
+		}
+	}
+	return record
+}
+
+// defaultProject names the project of dir after the directory it is in.
+func defaultProject(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		return filepath.Base(abs)
+	}
+	return dir
+}
+
+// historyTrend aggregates the records of one period.
+type historyTrend struct {
+	Period   string `json:"period"`
+	Checks   int    `json:"checks"`
+	Breaking int    `json:"breaking"`
+	Added    int    `json:"added"`
+	Removed  int    `json:"removed"`
+	// Symbols is the size of the API at the end of the period
+	Symbols int `json:"symbols"`
+}
+
+func periodOf(t time.Time, period string) (string, error) {
+	switch period {
+	case "day":
+		return t.Format("2006-01-02"), nil
+	case "month":
+		return t.Format("2006-01"), nil
+	case "quarter":
+		return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())+2)/3), nil
+	case "year":
+		return t.Format("2006"), nil
+	default:
+		return "", fmt.Errorf("unknown period %s, use day, month, quarter or year", period)
+	}
+}
+
+// trends groups records, which are sorted by time, by period.
+func trends(records []HistoryRecord, period string) ([]historyTrend, error) {
+	res := make([]historyTrend, 0)
+	for _, record := range records {
+		key, err := periodOf(record.Time, period)
+		if err != nil {
+			return nil, err
+		}
+		if len(res) == 0 || res[len(res)-1].Period != key {
+			res = append(res, historyTrend{Period: key})
+		}
+		trend := &res[len(res)-1]
+		trend.Checks++
+		trend.Breaking += record.Breaking
+		trend.Added += record.Added
+		trend.Removed += record.Removed
+		trend.Symbols = record.Symbols
+	}
+	return res, nil
+}
+
+// writeJSON responds with v as JSON, or with err, which is a failure of the store.
+func writeJSON(w http.ResponseWriter, v interface{}, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
+
+// runServe serves the comparison history recorded with -history for dashboards:
+//
+//	GET /projects                                   projects with recorded comparisons
+//	GET /projects/{project}/history                 every recorded comparison
+//	GET /projects/{project}/trends?period=quarter   breaking changes and API size per period
+func runServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", "localhost:8080", "address to listen on")
+	historySpec := flags.String("history", "", "history storage written by compare -history, like file:history.jsonl")
+	registerLogFlags(flags)
+	flags.Parse(args)
+	if err := setupLogging(); err != nil {
+		exitWithStatusError(err, 1)
+	}
+	if *historySpec == "" {
+		exitWithStatusString("serve: -history is required", 1)
+	}
+	store, err := openHistory(*historySpec)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /projects", func(w http.ResponseWriter, r *http.Request) {
+		projects, err := store.Projects()
+		writeJSON(w, projects, err)
+	})
+	mux.HandleFunc("GET /projects/{project}/history", func(w http.ResponseWriter, r *http.Request) {
+		records, err := store.Records(r.PathValue("project"))
+		writeJSON(w, records, err)
+	})
+	mux.HandleFunc("GET /projects/{project}/trends", func(w http.ResponseWriter, r *http.Request) {
+		period := r.URL.Query().Get("period")
+		if period == "" {
+			period = "quarter"
+		}
+		if _, err := periodOf(time.Time{}, period); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		records, err := store.Records(r.PathValue("project"))
+		if err != nil {
+			writeJSON(w, nil, err)
+			return
+		}
+		res, err := trends(records, period)
+		writeJSON(w, res, err)
+	})
+	slog.Info(fmt.Sprintf("serving history from %s on %s", *historySpec, *addr), "history", *historySpec, "addr", *addr)
+	err = http.ListenAndServe(*addr, logRequests(mux))
+	store.Close()
+	exitWithStatusError(err, 1)
+}
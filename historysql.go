@@ -0,0 +1,142 @@
+package exports
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqlDialect is what the SQL history store needs to know about a database.
+type sqlDialect struct {
+	name string
+	// drivers are the names database/sql drivers for the database register as
+	drivers []string
+	// serial is the type of an auto-incrementing key
+	serial string
+	// numbered placeholders, like $1, rather than ?
+	numbered bool
+}
+
+var (
+	sqliteDialect   = sqlDialect{name: "sqlite", drivers: []string{"sqlite", "sqlite3"}, serial: "INTEGER PRIMARY KEY AUTOINCREMENT"}
+	postgresDialect = sqlDialect{name: "postgres", drivers: []string{"postgres", "pgx"}, serial: "BIGSERIAL PRIMARY KEY", numbered: true}
+)
+
+// bind rewrites the ? placeholders of query for the dialect.
+func (d sqlDialect) bind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	buf := new(strings.Builder)
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			buf.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+// sqlHistory keeps records in a table of a SQLite or Postgres database, which lets
+// several servers share them. The driver is not part of this package, nor linked into
+// cmd/symbol-check: programs running Main link one in, like modernc.org/sqlite or
+// github.com/lib/pq, and the sqlite and postgres schemes fail without one.
+type sqlHistory struct {
+	db      *sql.DB
+	dialect sqlDialect
+}
+
+func openSQLHistory(dialect sqlDialect, location string) (HistoryStore, error) {
+	driver := ""
+	for _, name := range sql.Drivers() {
+		for _, candidate := range dialect.drivers {
+			if name == candidate && driver == "" {
+				driver = name
+			}
+		}
+	}
+	if driver == "" {
+		return nil, fmt.Errorf("history storage %s needs a database/sql driver registered as %s, which this build does not link, see RegisterHistoryStore", dialect.name, strings.Join(dialect.drivers, " or "))
+	}
+	db, err := sql.Open(driver, location)
+	if err != nil {
+		return nil, err
+	}
+	// times are kept as RFC 3339 text in UTC, which every database sorts alike
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS symbol_check_history (
+	id ` + dialect.serial + `,
+	project TEXT NOT NULL,
+	checked_at TEXT NOT NULL,
+	reference TEXT NOT NULL,
+	commit_id TEXT NOT NULL,
+	symbols INTEGER NOT NULL,
+	breaking INTEGER NOT NULL,
+	warnings INTEGER NOT NULL,
+	added INTEGER NOT NULL,
+	removed INTEGER NOT NULL,
+	compatible BOOLEAN NOT NULL
+)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s history: %v", dialect.name, err)
+	}
+	return &sqlHistory{db: db, dialect: dialect}, nil
+}
+
+func (h *sqlHistory) Append(record HistoryRecord) error {
+	_, err := h.db.Exec(h.dialect.bind(`INSERT INTO symbol_check_history
+	(project, checked_at, reference, commit_id, symbols, breaking, warnings, added, removed, compatible)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		record.Project, record.Time.UTC().Format(time.RFC3339), record.Reference, record.Commit,
+		record.Symbols, record.Breaking, record.Warnings, record.Added, record.Removed, record.Compatible)
+	return err
+}
+
+func (h *sqlHistory) Records(project string) ([]HistoryRecord, error) {
+	rows, err := h.db.Query(h.dialect.bind(`SELECT project, checked_at, reference, commit_id, symbols, breaking, warnings, added, removed, compatible
+	FROM symbol_check_history WHERE project = ? ORDER BY checked_at, id`), project)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]HistoryRecord, 0)
+	for rows.Next() {
+		var record HistoryRecord
+		var checkedAt string
+		if err := rows.Scan(&record.Project, &checkedAt, &record.Reference, &record.Commit, &record.Symbols,
+			&record.Breaking, &record.Warnings, &record.Added, &record.Removed, &record.Compatible); err != nil {
+			return nil, err
+		}
+		if record.Time, err = time.Parse(time.RFC3339, checkedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, record)
+	}
+	return res, rows.Err()
+}
+
+func (h *sqlHistory) Close() error {
+	return h.db.Close()
+}
+
+func (h *sqlHistory) Projects() ([]string, error) {
+	rows, err := h.db.Query(`SELECT DISTINCT project FROM symbol_check_history ORDER BY project`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]string, 0)
+	for rows.Next() {
+		var project string
+		if err := rows.Scan(&project); err != nil {
+			return nil, err
+		}
+		res = append(res, project)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,218 @@
+package exports
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSQL is a database/sql driver keeping the history table in memory. It knows
+// the statements of sqlHistory and nothing else, and keeps them to check the dialect.
+type fakeSQL struct {
+	mu  sync.Mutex
+	dbs map[string]*fakeDatabase
+}
+
+type fakeDatabase struct {
+	created bool
+	rows    [][]driver.Value
+	queries []string
+}
+
+func init() {
+	sql.Register("sqlite3", &fakeSQL{dbs: make(map[string]*fakeDatabase)})
+}
+
+func (d *fakeSQL) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dbs[name] == nil {
+		d.dbs[name] = new(fakeDatabase)
+	}
+	return &fakeConn{driver: d, db: d.dbs[name]}, nil
+}
+
+type fakeConn struct {
+	driver *fakeSQL
+	db     *fakeDatabase
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.driver.mu.Lock()
+	defer s.conn.driver.mu.Unlock()
+	db := s.conn.db
+	db.queries = append(db.queries, s.query)
+	switch {
+	case strings.HasPrefix(s.query, "CREATE TABLE IF NOT EXISTS symbol_check_history"):
+		db.created = true
+	case strings.HasPrefix(s.query, "INSERT INTO symbol_check_history"):
+		if !db.created {
+			return nil, errors.New("no such table: symbol_check_history")
+		}
+		if len(args) != 10 {
+			return nil, fmt.Errorf("inserted %d values, want 10", len(args))
+		}
+		db.rows = append(db.rows, args)
+	default:
+		return nil, fmt.Errorf("unexpected statement %s", s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.conn.driver.mu.Lock()
+	defer s.conn.driver.mu.Unlock()
+	db := s.conn.db
+	db.queries = append(db.queries, s.query)
+	switch {
+	case strings.HasPrefix(s.query, "SELECT DISTINCT project"):
+		seen := make(map[string]bool)
+		res := &fakeRows{columns: []string{"project"}}
+		for _, row := range db.rows {
+			if project := row[0].(string); !seen[project] {
+				seen[project] = true
+				res.rows = append(res.rows, []driver.Value{project})
+			}
+		}
+		sort.Slice(res.rows, func(i, j int) bool { return res.rows[i][0].(string) < res.rows[j][0].(string) })
+		return res, nil
+	case strings.Contains(s.query, "WHERE project = "):
+		res := &fakeRows{columns: make([]string, 10)}
+		for _, row := range db.rows {
+			if row[0] == args[0] {
+				res.rows = append(res.rows, row)
+			}
+		}
+		// ORDER BY checked_at, id
+		sort.SliceStable(res.rows, func(i, j int) bool { return res.rows[i][1].(string) < res.rows[j][1].(string) })
+		return res, nil
+	default:
+		return nil, fmt.Errorf("unexpected query %s", s.query)
+	}
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestSQLHistory(t *testing.T) {
+	store, err := openHistory("sqlite:" + t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	day := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	records := []HistoryRecord{
+		{Project: "plugin", Time: day.Add(48 * time.Hour), Reference: "v1.1.0", Commit: "bbb", Symbols: 12, Added: 2, Compatible: false},
+		{Project: "sdk", Time: day, Reference: "v2.0.0", Symbols: 40, Compatible: true},
+		// recorded in another time zone, but the same instant
+		{Project: "plugin", Time: day.In(time.FixedZone("CEST", 2*60*60)), Reference: "v1.0.0", Commit: "aaa", Symbols: 10, Breaking: 1, Warnings: 2, Removed: 1},
+	}
+	for _, record := range records {
+		if err := store.Append(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	projects, err := store.Projects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(projects, " "), "plugin sdk"; got != want {
+		t.Errorf("projects are %s, want %s", got, want)
+	}
+	got, err := store.Records("plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []HistoryRecord{records[2], records[0]}
+	want[0].Time = day
+	if len(got) != len(want) {
+		t.Fatalf("records are %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("record %d is %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSQLHistoryPlaceholders(t *testing.T) {
+	db, err := sql.Open("sqlite3", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS symbol_check_history ()`); err != nil {
+		t.Fatal(err)
+	}
+	store := &sqlHistory{db: db, dialect: postgresDialect}
+	if err := store.Append(HistoryRecord{Project: "plugin", Time: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Records("plugin"); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := db.Driver().(*fakeSQL)
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	for _, query := range fake.dbs[t.Name()].queries[1:] {
+		if strings.Contains(query, "?") {
+			t.Errorf("postgres query has ? placeholders: %s", query)
+		}
+	}
+	if insert := fake.dbs[t.Name()].queries[1]; !strings.Contains(insert, "VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)") {
+		t.Errorf("postgres insert is not numbered: %s", insert)
+	}
+}
+
+func TestSQLHistoryWithoutDriver(t *testing.T) {
+	// no postgres driver is linked into the tests, like into cmd/symbol-check
+	_, err := openHistory("postgres://localhost/history")
+	if err == nil {
+		t.Fatal("postgres history opened without a driver")
+	}
+	if !strings.Contains(err.Error(), "needs a database/sql driver registered as postgres or pgx") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package exports
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// hygieneRule checks a symbol added since the reference to package pkg, returning what is wrong with it or "".
+type hygieneRule func(pkg string, sym Symbol) string
+
+// initialisms are spelled in a consistent case, like ServeHTTP or UserID.
+var initialisms = []string{
+	"ACL", "API", "ASCII", "CPU", "CSS", "DNS", "EOF", "GUID", "HTML", "HTTP", "HTTPS", "ID",
+	"IP", "JSON", "LHS", "QPS", "RAM", "RHS", "RPC", "SLA", "SMTP", "SQL", "SSH", "TCP",
+	"TLS", "TTL", "UDP", "UI", "UID", "URI", "URL", "UTF8", "UUID", "VM", "XML", "XMPP", "XSRF", "XSS",
+}
+
+// isContext reports whether sym is context.Context, whatever name the file imports
+// context as. With the typed backend, aliases of it are recognized too.
+func isContext(sym Symbol) bool {
+	if sym.Resolved != "" {
+		return sym.Resolved == "context.Context"
+	}
+	return sym.SymbolType == "selector" && sym.PkgPath == "context" && strings.HasSuffix(sym.Label, ".Context")
+}
+
+// miscasedInitialism returns the first initialism in name written like a word, such as Url in ParseUrl.
+func miscasedInitialism(name string) string {
+	for _, initialism := range initialisms {
+		word := initialism[:1] + strings.ToLower(initialism[1:])
+		for i := strings.Index(name, word); i >= 0; {
+			end := i + len(word)
+			if end == len(name) || !unicode.IsLower(rune(name[end])) {
+				return word
+			}
+			next := strings.Index(name[end:], word)
+			if next < 0 {
+				break
+			}
+			i = end + next
+		}
+	}
+	return ""
+}
+
+// hygieneRules are the conventions that can be enabled with -hygiene. They only
+// apply to new exports, so existing API is never reported.
+var hygieneRules = map[string]hygieneRule{
+	"doc": func(pkg string, sym Symbol) string {
+		if !sym.Documented {
+			return "exported symbol has no doc comment"
+		}
+		return ""
+	},
+	"underscore": func(pkg string, sym Symbol) string {
+		if strings.Contains(sym.Label, "_") {
+			return "name contains an underscore"
+		}
+		return ""
+	},
+	"stutter": func(pkg string, sym Symbol) string {
+		if sym.ReceiverType != "" || len(sym.Label) <= len(pkg) || !strings.EqualFold(sym.Label[:len(pkg)], pkg) {
+			return ""
+		}
+		if unicode.IsUpper(rune(sym.Label[len(pkg)])) {
+			return fmt.Sprintf("name stutters, consider %s.%s", pkg, sym.Label[len(pkg):])
+		}
+		return ""
+	},
+	"context": func(pkg string, sym Symbol) string {
+		if sym.FuncSpec == nil {
+			return ""
+		}
+		for i, param := range sym.FuncSpec.Params {
+			if i > 0 && isContext(param) && !isContext(sym.FuncSpec.Params[0]) {
+				return fmt.Sprintf("context.Context should be the first parameter, not parameter %d", i)
+			}
+		}
+		return ""
+	},
+	"initialism": func(pkg string, sym Symbol) string {
+		if word := miscasedInitialism(sym.Label); word != "" {
+			return fmt.Sprintf("initialism %s should be written %s", word, strings.ToUpper(word))
+		}
+		return ""
+	},
+}
+
+// hygieneNames lists the conventions -hygiene accepts, sorted.
+func hygieneNames() []string {
+	res := make([]string, 0, len(hygieneRules))
+	for name := range hygieneRules {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// checkHygiene reports new exports of package pkg in diffs breaking an enabled convention.
+func (p Policy) checkHygiene(pkg string, diffs []Diff) []Diff {
+	res := make([]Diff, 0)
+	for _, diff := range diffs {
+		if !isNewExport(diff) {
+			continue
+		}
+		for _, name := range p.Hygiene {
+			if problem := hygieneRules[name](pkg, *diff.New); problem != "" {
+				res = append(res, Diff{
+					Kind:     DiffHygiene,
+					Symbol:   diff.Symbol,
+					Message:  fmt.Sprintf("%s: %s", name, problem),
+					Severity: SeverityBreaking,
+					New:      diff.New,
+				})
+			}
+		}
+	}
+	return res
+}
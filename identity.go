@@ -0,0 +1,36 @@
+package exports
+
+import "fmt"
+
+// PackageIdentity is the name and import path of a package, which every consumer
+// refers to it by. It is recorded in snapshots taken with -identity.
+type PackageIdentity struct {
+	Name       string `json:"name"`
+	ImportPath string `json:"importPath"`
+}
+
+// packageIdentity looks up the identity of the package pkgName in dir with go list.
+func packageIdentity(dir, pkgName string) (*PackageIdentity, error) {
+	name, err := packageName(dir, pkgName)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := listPackage(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find the import path of %s: %v", dir, err)
+	}
+	return &PackageIdentity{Name: name, ImportPath: pkg.ImportPath}, nil
+}
+
+// compareIdentity reports a package that was renamed or moved since the reference,
+// which breaks every consumer at once.
+func compareIdentity(reference, current *PackageIdentity) []Diff {
+	diffs := make([]Diff, 0)
+	if reference.Name != current.Name {
+		diffs = append(diffs, Diff{Kind: DiffChanged, Symbol: "package", Message: fmt.Sprintf("package renamed from %s to %s", reference.Name, current.Name), Severity: SeverityBreaking, Category: "package", Pointer: "/package/name"})
+	}
+	if reference.ImportPath != current.ImportPath {
+		diffs = append(diffs, Diff{Kind: DiffChanged, Symbol: "package", Message: fmt.Sprintf("package moved from %s to %s", reference.ImportPath, current.ImportPath), Severity: SeverityBreaking, Category: "package", Pointer: "/package/importPath"})
+	}
+	return diffs
+}
@@ -0,0 +1,159 @@
+package exports
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// consumerUsage is what a consumer module refers to in the package under check.
+type consumerUsage struct {
+	Dir string
+	// Symbols are package level identifiers used as pkg.Name
+	Symbols map[string]bool
+	// Selectors are every other selector name used by files importing the package.
+	// Receiver types are not resolved, so methods and fields are matched by name.
+	Selectors map[string]bool
+}
+
+// expandConsumers turns each directory into consumer modules: a module itself, or a
+// directory like a GOPATH or workspace whose subdirectories are modules.
+func expandConsumers(dirs []string) ([]string, error) {
+	res := make([]string, 0)
+	for _, dir := range dirs {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			res = append(res, dir)
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		modules := 0
+		for _, entry := range entries {
+			if _, err := os.Stat(filepath.Join(dir, entry.Name(), "go.mod")); entry.IsDir() && err == nil {
+				res = append(res, filepath.Join(dir, entry.Name()))
+				modules++
+			}
+		}
+		if modules == 0 {
+			res = append(res, dir)
+		}
+	}
+	return res, nil
+}
+
+// scanConsumer collects the uses of the package importPath in the Go files below dir.
+func scanConsumer(dir, importPath string) (*consumerUsage, error) {
+	usage := &consumerUsage{Dir: dir, Symbols: make(map[string]bool), Selectors: make(map[string]bool)}
+	err := filepath.Walk(dir, func(fileName string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if fileName != dir && (info.Name() == "vendor" || strings.HasPrefix(info.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(fileName, ".go") {
+			return nil
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), fileName, nil, parser.SkipObjectResolution)
+		if err != nil {
+			// a consumer that does not parse cannot be checked, but must not stop the others
+			return nil
+		}
+		local := ""
+		for _, spec := range file.Imports {
+			if path, err := strconv.Unquote(spec.Path.Value); err == nil && path == importPath {
+				local = importName(path)
+				if spec.Name != nil {
+					local = spec.Name.Name
+				}
+			}
+		}
+		if local == "" {
+			return nil
+		}
+		ast.Inspect(file, func(node ast.Node) bool {
+			if sel, ok := node.(*ast.SelectorExpr); ok {
+				if x, ok := sel.X.(*ast.Ident); ok && x.Name == local {
+					usage.Symbols[sel.Sel.Name] = true
+				} else {
+					usage.Selectors[sel.Sel.Name] = true
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	return usage, err
+}
+
+// affects reports whether the consumer refers to the symbol a breaking diff is about.
+func (u *consumerUsage) affects(diff Diff) bool {
+	ident := diff.Symbol
+	if strings.HasPrefix(ident, ".") {
+		return u.Symbols[strings.TrimPrefix(ident, ".")]
+	}
+	if i := strings.Index(ident, "."); i >= 0 {
+		// the receiver of a selector is not known, so a method is used when its name is selected
+		return u.Selectors[ident[i+1:]]
+	}
+	return false
+}
+
+// printImpact lists which consumers refer to symbols with breaking changes.
+func printImpact(w io.Writer, consumers []*consumerUsage, diffs []Diff) {
+	broken := 0
+	lines := make([]string, 0)
+	for _, consumer := range consumers {
+		idents := make([]string, 0)
+		seen := make(map[string]bool)
+		for _, diff := range diffs {
+			if policy.fails(diff) && !seen[diff.Symbol] && consumer.affects(diff) {
+				seen[diff.Symbol] = true
+				idents = append(idents, diff.Symbol)
+			}
+		}
+		if len(idents) == 0 {
+			continue
+		}
+		broken++
+		sort.Strings(idents)
+		lines = append(lines, fmt.Sprintf("\t%s: %s", consumer.Dir, strings.Join(idents, ", ")))
+	}
+	fmt.Fprintf(w, "impact: breaks %d of %d consumers\n", broken, len(consumers))
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// scanConsumers collects the uses of the package in dir by every consumer module.
+func scanConsumers(dirs []string, dir string) ([]*consumerUsage, error) {
+	pkg, err := listPackage(dir)
+	if err != nil {
+		return nil, err
+	}
+	modules, err := expandConsumers(dirs)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*consumerUsage, 0, len(modules))
+	for _, module := range modules {
+		usage, err := scanConsumer(module, pkg.ImportPath)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, usage)
+	}
+	return res, nil
+}
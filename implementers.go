@@ -0,0 +1,54 @@
+package exports
+
+import (
+	"fmt"
+	"go/ast"
+	"strings"
+)
+
+// implementedByDirective annotates who implements an interface, overriding
+// -interface-additions and -interface-removals for it:
+//
+//	//symbol-check:implemented-by consumers
+//
+// Interfaces implemented by consumers, like those of plugins, break when methods are
+// added, while those only the package implements, like a host handed to plugins, can
+// grow like concrete types.
+const implementedByDirective = "//symbol-check:implemented-by "
+
+// implementedBy returns who implements the interface documented by doc, consumers
+// or the package, or "" if it is not annotated.
+func implementedBy(doc *ast.CommentGroup) (string, error) {
+	if doc == nil {
+		return "", nil
+	}
+	for _, comment := range doc.List {
+		if !strings.HasPrefix(comment.Text, implementedByDirective) {
+			continue
+		}
+		switch who := strings.TrimSpace(strings.TrimPrefix(comment.Text, implementedByDirective)); who {
+		case "consumers", "package":
+			return who, nil
+		default:
+			return "", fmt.Errorf("interfaces are implemented by consumers or package, got %s", who)
+		}
+	}
+	return "", nil
+}
+
+// interfaceChange explains a method added to or removed from an interface implemented
+// by who, and rates it. Methods added to interfaces only the package implements are
+// rated like those added to concrete types, and removals from interfaces consumers
+// implement only break code calling the method, so they warn.
+func (p Policy) interfaceChange(who string, kind DiffKind) (string, Severity) {
+	switch {
+	case who == "package" && kind == DiffAdded:
+		return ", which only the package implements", p.MethodAdditions
+	case who == "consumers" && kind == DiffRemoved:
+		return ", which only breaks consumers calling it, implementations keep compiling", SeverityWarning
+	case kind == DiffAdded:
+		return ", which breaks implementers", SeverityBreaking
+	default:
+		return ", which breaks callers", SeverityBreaking
+	}
+}
@@ -0,0 +1,118 @@
+package exports
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// logFormat and logLevel configure the logs on stderr, see setupLogging. Reports
+// are not logs, they are written as they are whatever the format.
+var logFormat, logLevel string
+
+// registerLogFlags registers the logging flags on flags, which the command and
+// its long running subcommands share.
+func registerLogFlags(flags *flag.FlagSet) {
+	flags.StringVar(&logFormat, "log-format", "plain", "format of logs on stderr: plain messages, or text and json for log aggregation")
+	flags.StringVar(&logLevel, "log-level", "info", "least severe logs written: debug, which -vv implies, info, warn or error")
+}
+
+// setupLogging makes the default logger write to stderr in -log-format, from -log-level on.
+func setupLogging() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return fmt.Errorf("unknown log level %s, use debug, info, warn or error", logLevel)
+	}
+	if tracing {
+		level = slog.LevelDebug
+	}
+	tracing = level <= slog.LevelDebug
+	options := &slog.HandlerOptions{Level: level}
+	switch logFormat {
+	case "plain":
+		slog.SetDefault(slog.New(newPlainHandler(os.Stderr, level)))
+	case "text":
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, options)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, options)))
+	default:
+		return fmt.Errorf("unknown log format %s, use plain, text or json", logFormat)
+	}
+	return nil
+}
+
+// plainHandler writes just the message of every record, as people read logs on
+// a terminal. Attributes are for log aggregation and left out, so messages carry
+// everything worth reading. Debug records are traces and marked as such.
+type plainHandler struct {
+	w     io.Writer
+	level slog.Level
+	mu    *sync.Mutex
+}
+
+func newPlainHandler(w io.Writer, level slog.Level) *plainHandler {
+	return &plainHandler{w: w, level: level, mu: new(sync.Mutex)}
+}
+
+func (h *plainHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *plainHandler) Handle(ctx context.Context, r slog.Record) error {
+	line := r.Message + "\n"
+	if r.Level < slog.LevelInfo {
+		line = "trace: " + line
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, line)
+	return err
+}
+
+func (h *plainHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h
+}
+
+func (h *plainHandler) WithGroup(name string) slog.Handler {
+	return h
+}
+
+// exitLevel is the level the verdict of a run exiting with code is logged at.
+func exitLevel(code int) slog.Level {
+	switch code {
+	case 0:
+		return slog.LevelInfo
+	case 1:
+		return slog.LevelError
+	default:
+		return slog.LevelWarn
+	}
+}
+
+// statusRecorder remembers the status code of a response, for request logs.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// logRequests logs every request served by next with its status and duration.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		slog.Info(fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, rec.status),
+			"method", r.Method, "path", r.URL.Path, "status", rec.status, "duration", time.Since(start))
+	})
+}
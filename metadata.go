@@ -0,0 +1,96 @@
+package exports
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// symbolMeta are the -meta values, pattern:key=value, annotating the symbols of snapshots
+// with metadata like the team owning them.
+var symbolMeta stringList
+
+// parseSymbolMeta checks -meta values.
+func parseSymbolMeta(values []string) error {
+	for _, value := range values {
+		pattern, pair, ok := strings.Cut(value, ":")
+		key, _, hasKey := strings.Cut(pair, "=")
+		if !ok || pattern == "" || !hasKey || key == "" {
+			return fmt.Errorf("-meta takes pattern:key=value, like Client.*:owner=team-net, got %s", value)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad -meta pattern %s: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// annotateSymbols sets the metadata of the symbols matching -meta patterns, which match
+// names like suppressions do. Later values override earlier ones.
+func annotateSymbols(exports SymbolList) {
+	for i := range exports {
+		name := strings.TrimPrefix(exports[i].Ident(), ".")
+		for _, value := range symbolMeta {
+			pattern, pair, _ := strings.Cut(value, ":")
+			key, val, _ := strings.Cut(pair, "=")
+			if matched, _ := path.Match(pattern, name); !matched {
+				continue
+			}
+			if exports[i].Metadata == nil {
+				exports[i].Metadata = make(map[string]string)
+			}
+			exports[i].Metadata[key] = val
+		}
+	}
+}
+
+// mergeMetadata returns the metadata of old with that of sym on top, so updating a
+// snapshot keeps what it was annotated with.
+func mergeMetadata(old, sym map[string]string) map[string]string {
+	if len(old) == 0 {
+		return sym
+	}
+	res := make(map[string]string, len(old)+len(sym))
+	for key, value := range old {
+		res[key] = value
+	}
+	for key, value := range sym {
+		res[key] = value
+	}
+	return res
+}
+
+// annotateFindings copies the metadata of the reference symbols findings are about to
+// them. Methods take the metadata of their type for keys they have none for.
+func annotateFindings(reference SymbolList, diffs []Diff) {
+	byIdent := make(map[string]map[string]string)
+	for _, sym := range reference {
+		if len(sym.Metadata) > 0 {
+			byIdent[sym.Ident()] = sym.Metadata
+		}
+	}
+	if len(byIdent) == 0 {
+		return
+	}
+	for i := range diffs {
+		metadata := byIdent[diffs[i].Symbol]
+		if owner, _, _ := strings.Cut(diffs[i].Symbol, "."); owner != "" {
+			metadata = mergeMetadata(byIdent["."+owner], metadata)
+		}
+		diffs[i].Metadata = metadata
+	}
+}
+
+// formatMetadata renders metadata as key=value pairs, sorted by key.
+func formatMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		keys[i] = key + "=" + metadata[key]
+	}
+	return strings.Join(keys, " ")
+}
@@ -0,0 +1,137 @@
+package exports
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// comparison is the result of comparing the current symbols against one reference.
+type comparison struct {
+	Reference string
+	Meta      *BaselineMeta
+	Package   *PackageIdentity
+	// Surface is the size of the API of the reference, see surfaceSize
+	Surface int
+	Diffs   []Diff
+	Err     error
+}
+
+// compareAll compares the current symbols against every reference concurrently.
+// The current symbols are extracted once and only read, and results are returned
+// in the order of references, so the output does not depend on scheduling. With
+// -fail-fast, the results end with the first failing comparison.
+func compareAll(references []string, current SymbolList) []comparison {
+	res := make([]comparison, len(references))
+	if tracing || failFast {
+		// traces of concurrent comparisons would interleave, and failing fast
+		// leaves the references after the first failing one out
+		for i, reference := range references {
+			tracef("comparing against %s", reference)
+			res[i] = compareReference(reference, current)
+			if failFast && (res[i].Err != nil || policy.failsFast(optionsStructs(current), res[i].Diffs)) {
+				return res[:i+1]
+			}
+		}
+		return res
+	}
+	var wg sync.WaitGroup
+	for i, reference := range references {
+		wg.Add(1)
+		go func(i int, reference string) {
+			defer wg.Done()
+			res[i] = compareReference(reference, current)
+		}(i, reference)
+	}
+	wg.Wait()
+	return res
+}
+
+func compareReference(reference string, current SymbolList) (res comparison) {
+	res.Reference = reference
+	defer func() {
+		if r := recover(); r != nil {
+			res.Err = internalError(r)
+		}
+	}()
+	if trustedKeys != "" {
+		// a package of a module snapshot is covered by the signature of the file
+		fileName, _, _ := splitPackageReference(reference)
+		if res.Err = verifyReference(fileName, trustedKeys); res.Err != nil {
+			return res
+		}
+	}
+	var refData *Baseline
+	var err error
+	if baselineStore != nil {
+		refData, err = loadStoredReference(baselineStore, reference)
+	} else {
+		refData, err = loadReference(reference)
+	}
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.Meta = refData.Meta
+	if !refData.tree && refData.Meta != nil && refData.Meta.Target != targetName() {
+		slog.Warn(fmt.Sprintf("%s was taken for %s, but %s is read", reference, describeTarget(refData.Meta.Target), describeTarget(targetName())), "reference", reference)
+	}
+	res.Package = refData.Package
+	res.Surface = surfaceSize(refData.Symbols)
+	if refData.tree && !captureDocs || !refData.tree && (refData.Meta == nil || !refData.Meta.Docs) {
+		// comments of the reference are unknown, so changes to them cannot be told
+		current = withoutDocs(current)
+	}
+	if !refData.tree && (refData.Meta == nil || !refData.Meta.Tags) {
+		current = withoutTags(current)
+	}
+	if !refData.tree && (refData.Meta == nil || !refData.Meta.Receivers) {
+		current = withoutPointerReceivers(current)
+	}
+	if !refData.tree && (refData.Meta == nil || !refData.Meta.Consts) {
+		current = withoutConsts(current)
+	}
+	if refData.tree && !constValues || !refData.tree && (refData.Meta == nil || !refData.Meta.ConstValues) {
+		current = withoutConstValues(current)
+	}
+	if !refData.tree && (refData.Meta == nil || !refData.Meta.Params) {
+		current = withoutGroupedParams(current)
+	}
+	if !refData.tree && (refData.Meta == nil || !refData.Meta.Fields) {
+		current = withoutGroupedFields(current)
+	}
+	if !refData.tree && (refData.Meta == nil || !refData.Meta.ZeroValues) {
+		current = withoutZeroValues(current)
+	}
+	if !refData.tree && (refData.Meta == nil || !refData.Meta.Constraints) {
+		current = withoutConstraints(current)
+	}
+	if !refData.tree && (refData.Meta == nil || !refData.Meta.Embeds) {
+		current = withoutEmbeddedMethods(current, false)
+	}
+	source := changedSymbols(refData.Symbols)
+	if resolver == nil {
+		// promoted methods of opaque types are only known with the typed backend
+		source = withoutPromotedOpaque(source)
+	}
+	if resolver == nil || !resolvesExternal(source) {
+		// so are the methods of interfaces of other packages embedded in interfaces
+		source, current = withoutEmbeddedMethods(source, true), withoutEmbeddedMethods(current, true)
+	}
+	source, current = flattenInterfaces(source), flattenInterfaces(current)
+	res.Diffs = append(compare(source, current), policy.checkPlatformTypes(refData.Symbols, current)...)
+	annotateFindings(refData.Symbols, res.Diffs)
+	for i := range res.Diffs {
+		res.Diffs[i].Reference = reference
+		res.Diffs[i].Pointer = refData.pointer(source, res.Diffs[i].Pointer)
+	}
+	return res
+}
+
+func loadStoredReference(store BaselineStore, ref string) (*Baseline, error) {
+	name, version, err := splitBaselineRef(store, ref)
+	if err != nil {
+		return nil, err
+	}
+	return store.Load(name, version)
+}
@@ -0,0 +1,120 @@
+package exports
+
+import (
+	"go/types"
+	"sort"
+)
+
+// opaqueTypes finds the unexported types of the package that exported functions and
+// methods return, like client for func New() *client. Consumers cannot name such types,
+// but they call the exported methods of the values they get. Types returned by methods
+// of opaque types are opaque as well.
+func (r *typeResolver) opaqueTypes() map[string]*types.Named {
+	res := make(map[string]*types.Named)
+	queue := make([]*types.Signature, 0)
+	methods := func(typ types.Type) {
+		set := types.NewMethodSet(types.NewPointer(typ))
+		for i := 0; i < set.Len(); i++ {
+			if fn := set.At(i).Obj(); fn.Exported() {
+				queue = append(queue, fn.Type().(*types.Signature))
+			}
+		}
+	}
+	scope := r.pkg.Scope()
+	for _, name := range scope.Names() {
+		switch obj := scope.Lookup(name).(type) {
+		case *types.Func:
+			if obj.Exported() {
+				queue = append(queue, obj.Type().(*types.Signature))
+			}
+		case *types.TypeName:
+			if obj.Exported() {
+				methods(obj.Type())
+			}
+		}
+	}
+	for len(queue) > 0 {
+		results := queue[0].Results()
+		queue = queue[1:]
+		for i := 0; i < results.Len(); i++ {
+			typ := results.At(i).Type()
+			if ptr, ok := typ.(*types.Pointer); ok {
+				typ = ptr.Elem()
+			}
+			named, ok := typ.(*types.Named)
+			if !ok || named.Obj().Pkg() != r.pkg || named.Obj().Exported() || res[named.Obj().Name()] != nil {
+				continue
+			}
+			res[named.Obj().Name()] = named
+			methods(named)
+		}
+	}
+	return res
+}
+
+// opaqueMethods marks the exported methods of opaque types, see opaqueTypes, in
+// symbols extracted from dir and adds those promoted to them through embedded fields,
+// which are not declared with the type. It does nothing without the typed backend.
+func (r *typeResolver) opaqueMethods(dir string, symbols SymbolList) SymbolList {
+	if r == nil || dir != r.dir {
+		return symbols
+	}
+	opaque := r.opaqueTypes()
+	declared := make(map[string]bool)
+	for i := range symbols {
+		if sym := &symbols[i]; sym.SymbolType == "method" && opaque[sym.ReceiverType] != nil {
+			sym.Opaque = true
+			declared[sym.Ident()] = true
+		}
+	}
+	names := make([]string, 0, len(opaque))
+	for name := range opaque {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		set := types.NewMethodSet(types.NewPointer(opaque[name]))
+		for i := 0; i < set.Len(); i++ {
+			fn := set.At(i).Obj()
+			sym := Symbol{Label: fn.Name(), SymbolType: "method", ReceiverType: name, Opaque: true}
+			if !fn.Exported() || declared[sym.Ident()] {
+				continue
+			}
+			sig := fn.Type().(*types.Signature)
+			sym.FuncSpec = &FuncSpec{Params: r.tupleSymbols(sig.Params(), sig.Variadic()), Returns: r.tupleSymbols(sig.Results(), false)}
+			symbols = append(symbols, sym)
+		}
+	}
+	return symbols
+}
+
+// tupleSymbols records parameters or results the way funcSpec records them from source.
+func (r *typeResolver) tupleSymbols(tuple *types.Tuple, variadic bool) SymbolList {
+	if tuple.Len() == 0 {
+		return nil
+	}
+	res := make(SymbolList, tuple.Len())
+	for i := range res {
+		typ := tuple.At(i).Type()
+		if variadic && i == len(res)-1 {
+			res[i] = Symbol{Label: "..." + writtenType(r.symbolFromType(typ.(*types.Slice).Elem())), SymbolType: "variadic"}
+		} else {
+			res[i] = *r.symbolFromType(typ)
+		}
+		// like the types of variadic parameters resolved from source, which are slices
+		res[i].Resolved = r.canonicalType(typ)
+	}
+	return res
+}
+
+// withoutPromotedOpaque leaves out the methods opaqueMethods adds for promoted methods,
+// which are only known with the typed backend.
+func withoutPromotedOpaque(symbols SymbolList) SymbolList {
+	res := make(SymbolList, 0, len(symbols))
+	for _, sym := range symbols {
+		if !sym.Opaque || sym.FileName != "" {
+			res = append(res, sym)
+		}
+	}
+	return res
+}
@@ -0,0 +1,48 @@
+package exports
+
+import (
+	"strings"
+)
+
+// isOptionsName reports whether a type is named like an options struct, FooOptions or FooOpts.
+func isOptionsName(name string) bool {
+	return strings.HasSuffix(name, "Options") || strings.HasSuffix(name, "Opts")
+}
+
+// optionsStructs finds the structs following the options struct pattern: named like
+// one and taken as a parameter, by value or pointer, by an exported function or method.
+// Callers set their fields by name, so new fields keep every caller compiling.
+func optionsStructs(symbols SymbolList) map[string]bool {
+	structs := make(map[string]bool)
+	for _, sym := range symbols {
+		if sym.SymbolType == "struct" && !sym.Unexported && isOptionsName(sym.Label) {
+			structs[sym.Label] = true
+		}
+	}
+	res := make(map[string]bool)
+	for _, sym := range symbols {
+		if sym.Unexported || sym.FuncSpec == nil {
+			continue
+		}
+		for _, param := range sym.FuncSpec.Params {
+			name := param.UnderlyingType
+			if param.SymbolType == "star" {
+				name = strings.TrimPrefix(param.Label, "*")
+			}
+			if structs[name] {
+				res[name] = true
+			}
+		}
+	}
+	return res
+}
+
+// relaxOptionsFields gives fields added to options structs, see optionsStructs, the severity of the policy.
+func (p Policy) relaxOptionsFields(options map[string]bool, diffs []Diff) {
+	for i := range diffs {
+		if diffs[i].Category == "member-added" && options[strings.TrimPrefix(diffs[i].Symbol, ".")] {
+			diffs[i].Message += ", to an options struct"
+			diffs[i].Severity = p.OptionsAdditions
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package exports
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// reportOutput is a destination of the report in one format, see -out. Dest is stdout,
+// stderr or a file name.
+type reportOutput struct {
+	Format string
+	Dest   string
+}
+
+// outputs are the -out values, format=destination like sarif=report.sarif
+var outputs stringList
+
+// reportTo are the parsed outputs, see parseOutputs.
+var reportTo []reportOutput
+
+// parseOutputs parses -out values. Without any, the report is written in the -format,
+// text on stderr alongside the verdict and other formats on stdout. human is another
+// name for the text format, and - for stdout.
+func parseOutputs(values []string, format string) ([]reportOutput, error) {
+	if len(values) == 0 {
+		dest := "stdout"
+		if format == "text" {
+			dest = "stderr"
+		}
+		return []reportOutput{{Format: format, Dest: dest}}, nil
+	}
+	res := make([]reportOutput, 0, len(values))
+	for _, value := range values {
+		format, dest, ok := strings.Cut(value, "=")
+		if !ok || dest == "" {
+			return nil, fmt.Errorf("-out takes format=destination, like sarif=report.sarif, got %s", value)
+		}
+		if format == "human" {
+			format = "text"
+		}
+		if _, ok := Renderers[format]; !ok {
+			return nil, fmt.Errorf("unknown output format %s", format)
+		}
+		if dest == "-" {
+			dest = "stdout"
+		}
+		res = append(res, reportOutput{Format: format, Dest: dest})
+	}
+	return res, nil
+}
+
+// inlineText reports whether the text report is written to stderr, where it is
+// printed package by package along with the rest of their reports.
+func inlineText() bool {
+	if reportTo == nil {
+		return outputFormat == "text"
+	}
+	for _, out := range reportTo {
+		if out.Format == "text" && out.Dest == "stderr" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeReports writes the differences to every output but the inline text report.
+func writeReports(diffs []Diff) error {
+	for _, out := range reportTo {
+		if out.Format == "text" && out.Dest == "stderr" {
+			continue
+		}
+		renderer, ok := Renderers[out.Format]
+		if !ok {
+			return fmt.Errorf("unknown output format %s", out.Format)
+		}
+		report, err := renderer.Render(Report{Diffs: diffs})
+		if err != nil {
+			return err
+		}
+		switch out.Dest {
+		case "stdout":
+			_, err = os.Stdout.Write(report)
+		case "stderr":
+			_, err = os.Stderr.Write(report)
+		default:
+			err = os.WriteFile(out.Dest, report, 0644)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,374 @@
+package exports
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// packageCheck is a package to compare against its references.
+type packageCheck struct {
+	Dir        string
+	PkgName    string
+	References []string
+	// ImportPath is set for packages of a module checked with -r, which are named by it.
+	// Gone marks packages of the module snapshot no longer in the module.
+	ImportPath string
+	Gone       bool
+}
+
+func (c packageCheck) String() string {
+	if c.ImportPath != "" {
+		return c.ImportPath
+	}
+	if c.PkgName != "" {
+		return c.Dir + ":" + c.PkgName
+	}
+	return c.Dir
+}
+
+// packageResult is the outcome of a packageCheck. Its text report is buffered,
+// so that packages checked in parallel are reported in a fixed order.
+type packageResult struct {
+	Check  packageCheck
+	Output bytes.Buffer
+	// Diffs holds the findings against every reference, each reported once
+	Diffs      []Diff
+	Compatible bool
+	// GateFailed is set when a gate, like -max-new-exports, failed compare, which
+	// is not only because of additions even when every finding is one
+	GateFailed bool
+	Err        error
+	// Suppressions used by the findings of this package
+	Used map[string]bool
+}
+
+// parsePackageChecks groups -package dir[:pkg]=reference values by package, so a
+// package can be compared against several references by repeating it.
+func parsePackageChecks(values []string) ([]packageCheck, error) {
+	res := make([]packageCheck, 0)
+	index := make(map[string]int)
+	for _, value := range values {
+		i := strings.LastIndex(value, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("-package takes dir=reference, got %s", value)
+		}
+		check := packageCheck{Dir: value[:i]}
+		if j := strings.LastIndex(check.Dir, ":"); j > 1 {
+			check.Dir, check.PkgName = check.Dir[:j], check.Dir[j+1:]
+		}
+		key := check.String()
+		if _, ok := index[key]; !ok {
+			index[key] = len(res)
+			res = append(res, check)
+		}
+		res[index[key]].References = append(res[index[key]].References, value[i+1:])
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].String() < res[j].String() })
+	return res, nil
+}
+
+// checkPackages checks every package concurrently and returns the results in the order of checks.
+// With -fail-fast, packages are checked one after the other up to the first incompatible one.
+// Comparisons are recorded in history, unless it is nil.
+func checkPackages(checks []packageCheck, labelled bool, history HistoryStore) []*packageResult {
+	res := make([]*packageResult, len(checks))
+	if failFast {
+		for i, check := range checks {
+			if res[i] = checkPackage(check, labelled, history); !res[i].Compatible {
+				return res[:i+1]
+			}
+		}
+		return res
+	}
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check packageCheck) {
+			defer wg.Done()
+			res[i] = checkPackage(check, labelled, history)
+		}(i, check)
+	}
+	wg.Wait()
+	return res
+}
+
+// projectName is the name comparison history of a package is recorded under.
+func projectName(check packageCheck, labelled bool) string {
+	switch {
+	case !labelled && project != "":
+		return project
+	case project != "":
+		return project + "/" + check.String()
+	default:
+		return defaultProject(check.Dir)
+	}
+}
+
+// checkPackage compares a package against its references. Labelled results name the
+// reference each report is for, which is needed as soon as there are several.
+func checkPackage(check packageCheck, labelled bool, history HistoryStore) *packageResult {
+	if check.ImportPath != "" && (check.Gone || len(check.References) == 0) {
+		return modulePackageChange(check)
+	}
+	res := &packageResult{Check: check, Compatible: true, Used: make(map[string]bool)}
+	fail := func(err error) *packageResult {
+		res.Err, res.Compatible = err, false
+		return res
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			fail(internalError(r))
+		}
+	}()
+	exports, err := extract(check.Dir, check.PkgName)
+	if err != nil {
+		return fail(err)
+	}
+	pkg := ""
+	if len(policy.Hygiene) > 0 {
+		if pkg, err = packageName(check.Dir, check.PkgName); err != nil {
+			return fail(err)
+		}
+	}
+	var usages []*consumerUsage
+	if len(consumers) > 0 {
+		if usages, err = scanConsumers(consumers, check.Dir); err != nil {
+			return fail(err)
+		}
+	}
+
+	w := &res.Output
+	tier := tierOf(check)
+	if tier != tierStable {
+		fmt.Fprintf(w, "%s package, its findings are rated accordingly\n", tier)
+	}
+	reported := make(map[string]bool)
+	labelled = labelled || len(check.References) > 1
+	for _, cmp := range compareAll(check.References, exports) {
+		if cmp.Err != nil {
+			return fail(cmp.Err)
+		}
+		switch {
+		case labelled && cmp.Meta != nil:
+			fmt.Fprintf(w, "comparing against %s, %s\n", cmp.Reference, cmp.Meta)
+		case labelled:
+			fmt.Fprintf(w, "comparing against %s\n", cmp.Reference)
+		case cmp.Meta != nil:
+			fmt.Fprintf(w, "comparing against %s\n", cmp.Meta)
+		}
+		diff := append(cmp.Diffs, buildVariants(exports)...)
+		if cmp.Package != nil {
+			identity, err := packageIdentity(check.Dir, check.PkgName)
+			if err != nil {
+				return fail(err)
+			}
+			diff = append(diff, compareIdentity(cmp.Package, identity)...)
+		}
+		if len(policy.Hygiene) > 0 {
+			diff = append(diff, policy.checkHygiene(pkg, diff)...)
+		}
+		diff = rateForTier(tier, policy.enabledDiffs(diff))
+		if suppressFile != "" {
+			before := len(diff)
+			var problems []string
+			diff, problems = policy.suppress(diff, res.Used, cmp.Meta)
+			if before-len(diff) > 0 {
+				fmt.Fprintf(w, "%d accepted findings suppressed\n", before-len(diff))
+			}
+			for _, problem := range problems {
+				fmt.Fprintln(w, problem)
+			}
+		}
+		if blame {
+			annotateBlame(diff)
+		}
+		// machine readable reports cover every reference in one document
+		if inlineText() {
+			printDiffSections(w, diff)
+		}
+		if explain {
+			printExplanations(w, diff)
+		}
+		if usages != nil {
+			printImpact(w, usages, diff)
+		}
+		// a finding against several references is reported once
+		for _, d := range diff {
+			if fingerprint := diffFingerprint(d); !reported[fingerprint] {
+				reported[fingerprint] = true
+				res.Diffs = append(res.Diffs, d)
+			}
+		}
+		traceVerdict(diff)
+		refCompatible := true
+		for _, d := range diff {
+			if policy.fails(d) {
+				refCompatible = false
+			}
+		}
+		// only stable packages are held to the gates on the size of their API
+		if err := policy.checkNewExports(diff); err != nil && tier == tierStable {
+			fmt.Fprintln(w, err)
+			refCompatible, res.GateFailed = false, true
+		}
+		// with -changed-only, only part of the API is extracted
+		if policy.surfaceGated() && changedFiles == nil && tier == tierStable {
+			fmt.Fprintln(w, surfaceDelta(cmp.Surface, surfaceSize(exports)))
+			if err := policy.checkSurface(cmp.Surface, surfaceSize(exports)); err != nil {
+				fmt.Fprintln(w, err)
+				refCompatible, res.GateFailed = false, true
+			}
+		}
+		if err := policy.checkFrozen(diff); err != nil {
+			fmt.Fprintln(w, err)
+			refCompatible, res.GateFailed = false, true
+		}
+		if err := policy.checkFreezeWindows(diff, time.Now()); err != nil {
+			fmt.Fprintln(w, err)
+			refCompatible, res.GateFailed = false, true
+		}
+		if history != nil {
+			if err := history.Append(newHistoryRecord(projectName(check, labelled), cmp.Reference, check.Dir, exports, diff, refCompatible)); err != nil {
+				return fail(err)
+			}
+		}
+		res.Compatible = res.Compatible && refCompatible
+	}
+	return res
+}
+
+// status is how a package is shown in the status table.
+func (r *packageResult) status() string {
+	switch {
+	case r.Err != nil:
+		return "error"
+	case r.Compatible:
+		return "compatible"
+	default:
+		return "not compatible"
+	}
+}
+
+// printStatusTable summarizes the results of several packages ahead of their reports.
+func printStatusTable(w io.Writer, results []*packageResult) {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "package\tstatus\tbreaking\twarnings")
+	for _, res := range results {
+		breaking, warnings := 0, 0
+		for _, diff := range res.Diffs {
+			switch {
+			case policy.fails(diff):
+				breaking++
+			case diff.Severity == SeverityWarning:
+				warnings++
+			}
+		}
+		fmt.Fprintf(table, "%s\t%s\t%d\t%d\n", res.Check, res.status(), breaking, warnings)
+	}
+	table.Flush()
+}
+
+// runChecks compares packages, writes their reports and exits, see evaluateChecks.
+// With -watch it keeps comparing them instead. If nothing changed since the last run,
+// its report and verdict are reused, see cacheKey.
+func runChecks(checks []packageCheck, multiple bool) {
+	if watch {
+		watchChecks(checks, multiple)
+	}
+	key := cacheKey(checks)
+	if cached, ok := loadCachedRun(key); ok {
+		replayCachedRun(os.Stderr, cached)
+		exitWithStatusString(cached.Verdict, cached.Code)
+	}
+	report := new(bytes.Buffer)
+	code, verdict := evaluateChecks(io.MultiWriter(os.Stderr, report), checks, multiple)
+	if code != 1 {
+		// errors may be gone on the next run without any change
+		storeCachedRun(key, cachedRun{Code: code, Verdict: verdict, Report: report.String()})
+	}
+	exitWithStatusString(verdict, code)
+}
+
+// evaluateChecks compares packages, writes their reports to w and returns the exit code
+// with the verdict. An error in any package results in 1, as its result is unknown;
+// otherwise any incompatible package results in 2, or in 3 if only additions, like
+// new exports, fail.
+func evaluateChecks(w io.Writer, checks []packageCheck, multiple bool) (int, string) {
+	policy.Suppressed = nil
+	if suppressFile != "" {
+		var err error
+		if policy.Suppressed, err = readSuppressions(suppressFile); err != nil {
+			return 1, err.Error()
+		}
+	}
+	var history HistoryStore
+	if historySpec != "" {
+		var err error
+		if history, err = openHistory(historySpec); err != nil {
+			return 1, err.Error()
+		}
+		defer history.Close()
+	}
+	results := checkPackages(checks, multiple, history)
+	if multiple {
+		printStatusTable(w, results)
+	}
+
+	all := make([]Diff, 0)
+	used := make(map[string]bool)
+	failed, compatible, breaking := false, true, false
+	for _, res := range results {
+		if multiple {
+			fmt.Fprintf(w, "\n== %s ==\n", res.Check)
+		}
+		w.Write(res.Output.Bytes())
+		if res.Err != nil && multiple {
+			slog.Error(res.Err.Error(), "package", res.Check.String())
+		}
+		failed = failed || res.Err != nil
+		compatible = compatible && res.Compatible
+		breaking = breaking || res.GateFailed
+		all = append(all, res.Diffs...)
+		for _, diff := range res.Diffs {
+			breaking = breaking || policy.fails(diff) && diff.Class() != ClassAdditive
+		}
+		for fingerprint := range res.Used {
+			used[fingerprint] = true
+		}
+	}
+	if failed && !multiple {
+		return 1, results[0].Err.Error()
+	}
+	if stale := policy.staleSuppressions(used); suppressFile != "" && len(stale) > 0 {
+		slog.Warn("suppressions matching no finding, they can be removed: "+strings.Join(stale, ", "), "suppressions", stale)
+	}
+	if err := writeReports(all); err != nil {
+		return 1, err.Error()
+	}
+	if rewritesFile != "" {
+		if err := writeRewrites(rewritesFile, suggestRewrites(all)); err != nil {
+			return 1, err.Error()
+		}
+	}
+	if failFast && !compatible {
+		slog.Info("stopped at the first failing finding, -fail-fast leaves the rest unchecked")
+	}
+	switch {
+	case failed:
+		return 1, "some packages could not be checked"
+	case compatible:
+		return 0, "symbols are compatible"
+	case !breaking:
+		return 3, "symbols are not compatible, only because of additions"
+	default:
+		return 2, "symbols are not compatible"
+	}
+}
@@ -0,0 +1,164 @@
+package exports
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// exitSource is the package the exit code tests compare changes of against.
+const exitSource = `package plugin
+
+// Plugin is implemented by plugins.
+type Plugin interface {
+	Enable() error
+}
+
+// Config configures a plugin.
+type Config struct {
+	Name string
+}
+
+func New(config *Config) Plugin {
+	return nil
+}
+`
+
+// setPolicy sets up the policy for a test like the flags do by default, then applies edit.
+func setPolicy(t *testing.T, edit func(p *Policy)) {
+	t.Helper()
+	saved := policy
+	t.Cleanup(func() { policy = saved })
+	policy = DefaultOptions().Policy
+	if edit != nil {
+		edit(&policy)
+	}
+}
+
+// writeReference snapshots a package consisting of source, and returns a check of
+// the package against the snapshot. Tests then change the package in check.Dir.
+func writeReference(t *testing.T, source string) packageCheck {
+	t.Helper()
+	dir := t.TempDir()
+	meta := new(BaselineMeta)
+	meta.recorded()
+	data, err := json.Marshal(&Baseline{Schema: baselineSchema, Meta: meta, Symbols: extractSource(t, dir, source)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reference := filepath.Join(t.TempDir(), "reference.json")
+	if err := ioutil.WriteFile(reference, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return packageCheck{Dir: dir, References: []string{reference}}
+}
+
+func TestEvaluateChecksExitCodes(t *testing.T) {
+	added := exitSource + "\nfunc Version() string { return \"\" }\n"
+	tests := []struct {
+		name   string
+		source string
+		policy func(p *Policy)
+		// freeze lists the symbols frozen in the reference
+		freeze stringList
+		// suppress is the content of the -suppress file
+		suppress string
+		code     int
+		// output is part of the report
+		output string
+	}{
+		{name: "unchanged", source: exitSource, code: 0},
+		{name: "does not parse", source: "package plugin\n\nfunc New(", code: 1},
+		{name: "removal", source: strings.Replace(exitSource, "func New(", "func newPlugin(", 1), code: 2},
+		{name: "addition", source: added, code: 3},
+		{name: "allowed addition", source: added, policy: func(p *Policy) { p.AllowAdditions = true }, code: 0},
+		{
+			name:   "new exports gate",
+			source: added,
+			policy: func(p *Policy) { p.MaxNewExports = 0 },
+			code:   2,
+			output: "1 new exported symbols exceed the limit of 0",
+		},
+		{
+			name:     "surface gate",
+			source:   strings.Replace(exitSource, "func New(", "func newPlugin(", 1),
+			policy:   func(p *Policy) { p.MaxSurfaceShrink = 0 },
+			suppress: "New replaced by plugins registering themselves",
+			code:     2,
+			output:   "API surface shrank by",
+		},
+		{
+			name:   "frozen addition",
+			source: strings.Replace(exitSource, "Name string", "Name string\n\tDebug bool", 1),
+			freeze: stringList{"Config"},
+			code:   2,
+			output: "frozen symbols changed, acknowledge with -unfreeze Config",
+		},
+		{
+			name:   "freeze window",
+			source: added,
+			policy: func(p *Policy) {
+				p.AllowAdditions = true
+				p.FreezeWindows = stringList{"2000-01-01..2999-12-31"}
+			},
+			code:   2,
+			output: "the API is frozen from 2000-01-01 to 2999-12-31, but 1 changes were found",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setPolicy(t, test.policy)
+			freeze = test.freeze
+			check := writeReference(t, exitSource)
+			freeze = nil
+			if test.suppress != "" {
+				suppressFile = filepath.Join(t.TempDir(), defaultSuppressFile)
+				if err := ioutil.WriteFile(suppressFile, []byte(test.suppress), 0644); err != nil {
+					t.Fatal(err)
+				}
+				defer func() { suppressFile = "" }()
+			}
+			if err := ioutil.WriteFile(filepath.Join(check.Dir, "plugin.go"), []byte(test.source), 0644); err != nil {
+				t.Fatal(err)
+			}
+			w := new(bytes.Buffer)
+			code, verdict := evaluateChecks(w, []packageCheck{check}, false)
+			if code != test.code {
+				t.Errorf("exit code is %d (%s), want %d, report:\n%s", code, verdict, test.code, w)
+			}
+			if !strings.Contains(w.String(), test.output) {
+				t.Errorf("report does not mention %q:\n%s", test.output, w)
+			}
+		})
+	}
+}
+
+func TestEvaluateChecksStaleSuppressions(t *testing.T) {
+	setPolicy(t, nil)
+	saved := slog.Default()
+	defer slog.SetDefault(saved)
+	logged := new(bytes.Buffer)
+	slog.SetDefault(slog.New(newPlainHandler(logged, slog.LevelInfo)))
+
+	check := writeReference(t, exitSource)
+	suppressFile = filepath.Join(t.TempDir(), defaultSuppressFile)
+	defer func() { suppressFile = "" }()
+	if err := ioutil.WriteFile(suppressFile, []byte("SC002 versions are additive\nLegacy*\n#0123456789abcdef\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	source := exitSource + "\nfunc Version() string { return \"\" }\n"
+	if err := ioutil.WriteFile(filepath.Join(check.Dir, "plugin.go"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := new(bytes.Buffer)
+	if code, verdict := evaluateChecks(w, []packageCheck{check}, false); code != 0 {
+		t.Errorf("exit code is %d (%s), want 0, report:\n%s", code, verdict, w)
+	}
+	if want := "suppressions matching no finding, they can be removed: 0123456789abcdef, Legacy*"; !strings.Contains(logged.String(), want) {
+		t.Errorf("log does not warn %q:\n%s", want, logged)
+	}
+}
@@ -0,0 +1,77 @@
+package exports
+
+import (
+	"fmt"
+)
+
+// variadic reports whether the last of the parameters is variadic, like ...int.
+func variadic(params SymbolList) bool {
+	return len(params) > 0 && params[len(params)-1].SymbolType == "variadic"
+}
+
+// variadicChange reports a last parameter that became variadic or stopped being so, which
+// changes the type of the func even where calls still compile. The parameters left to
+// compare are returned without it.
+func variadicChange(old, new SymbolList) ([]Diff, SymbolList, SymbolList) {
+	if len(old) != len(new) || variadic(old) == variadic(new) {
+		return nil, old, new
+	}
+	i := len(old) - 1
+	message := fmt.Sprintf("parameter is now variadic, %s instead of %s", typeExpr(new[i]), typeExpr(old[i]))
+	if variadic(old) {
+		message = fmt.Sprintf("parameter is no longer variadic, %s instead of %s", typeExpr(new[i]), typeExpr(old[i]))
+	}
+	diff := Diff{
+		Kind:     DiffChanged,
+		Message:  message,
+		Severity: SeverityBreaking,
+		Path:     []string{fmt.Sprintf("param %d", i)},
+		Category: "signature",
+		Pointer:  fmt.Sprintf("/funcSpec/params/%d", i),
+	}
+	return []Diff{diff}, old[:i], new[:i]
+}
+
+// withoutGroupedParams copies symbols leaving out the parameters and results declared
+// together with the previous one, like b in a, b int, for references taken when only
+// the first of them was recorded.
+func withoutGroupedParams(symbols SymbolList) SymbolList {
+	ungrouped := func(list SymbolList) SymbolList {
+		res := make(SymbolList, 0, len(list))
+		for _, param := range list {
+			if !param.Grouped {
+				res = append(res, param)
+			}
+		}
+		return res
+	}
+	res := make(SymbolList, len(symbols))
+	for i, sym := range symbols {
+		if sym.FuncSpec != nil {
+			sym.FuncSpec = &FuncSpec{Params: ungrouped(sym.FuncSpec.Params), Returns: ungrouped(sym.FuncSpec.Returns)}
+		}
+		if sym.Members != nil {
+			sym.Members = withoutGroupedParams(sym.Members)
+		}
+		res[i] = sym
+	}
+	return res
+}
+
+// withoutGroupedFields copies symbols leaving out the struct fields declared together
+// with the previous one, like Y in X, Y int, for references taken when only the first
+// of them was recorded.
+func withoutGroupedFields(symbols SymbolList) SymbolList {
+	if symbols == nil {
+		return nil
+	}
+	res := make(SymbolList, 0, len(symbols))
+	for _, sym := range symbols {
+		if sym.Grouped && sym.SymbolType == "member" {
+			continue
+		}
+		sym.Members = withoutGroupedFields(sym.Members)
+		res = append(res, sym)
+	}
+	return res
+}
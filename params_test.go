@@ -0,0 +1,203 @@
+package exports
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// paramsSource declares funcs with parameters and results of every layout.
+const paramsSource = `package plugin
+
+func New(name string, size int) (Plugin, error) {
+	return nil, nil
+}
+
+func Register(name string, opts ...Option) {}
+
+func Pos(x, y int, name string) (w, h int) {
+	return 0, 0
+}
+
+type Plugin interface{}
+
+type Option func()
+`
+
+// findingLines lists the symbol, path and message of every finding, one per line.
+func findingLines(diffs []Diff) string {
+	lines := make([]string, len(diffs))
+	for i, diff := range diffs {
+		lines[i] = strings.Join(append(append([]string{diff.Symbol}, diff.Path...), diff.Message), ": ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestCompareParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		want    string
+	}{
+		{name: "unchanged", current: paramsSource},
+		{
+			name:    "renamed",
+			current: strings.Replace(paramsSource, "New(name string, size int)", "New(id string, n int)", 1),
+		},
+		{
+			name:    "ungrouped",
+			current: strings.Replace(paramsSource, "Pos(x, y int, name string) (w, h int)", "Pos(x int, y int, name string) (w int, h int)", 1),
+		},
+		{
+			// params are compared by position, so both positions changed
+			name:    "reordered",
+			current: strings.Replace(paramsSource, "New(name string, size int)", "New(size int, name string)", 1),
+			want:    ".New: param 0: type changed from string to int\n.New: param 1: type changed from int to string",
+		},
+		{
+			name:    "reordered results",
+			current: strings.Replace(paramsSource, "(Plugin, error) {", "(error, Plugin) {", 1),
+			want:    ".New: result 0: type changed from Plugin to error\n.New: result 1: type changed from error to Plugin",
+		},
+		{
+			name:    "param added",
+			current: strings.Replace(paramsSource, "New(name string, size int)", "New(name string, size int, debug bool)", 1),
+			want:    ".New: params: number of parameters changed from 2 to 3",
+		},
+		{
+			name:    "grouped param changed",
+			current: strings.Replace(paramsSource, "Pos(x, y int, name string)", "Pos(x, y int64, name string)", 1),
+			want:    ".Pos: param 0: type changed from int to int64\n.Pos: param 1: type changed from int to int64",
+		},
+		{
+			name:    "variadic to slice",
+			current: strings.Replace(paramsSource, "opts ...Option", "opts []Option", 1),
+			want:    ".Register: param 1: parameter is no longer variadic, []Option instead of ...Option",
+		},
+		{
+			// the variadic change is reported once, besides the other params
+			name:    "variadic to slice and param changed",
+			current: strings.Replace(paramsSource, "Register(name string, opts ...Option)", "Register(name []byte, opts []Option)", 1),
+			want:    ".Register: param 1: parameter is no longer variadic, []Option instead of ...Option\n.Register: param 0: type changed from string to []byte",
+		},
+	}
+	reference := extractSource(t, t.TempDir(), paramsSource)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diffs := Compare(reference, extractSource(t, t.TempDir(), test.current), DefaultOptions())
+			if got := findingLines(diffs); got != test.want {
+				t.Errorf("findings are\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestVariadicChange(t *testing.T) {
+	dir := t.TempDir()
+	variadic := extractSource(t, dir, paramsSource)
+	slice := extractSource(t, dir, strings.Replace(paramsSource, "opts ...Option", "opts []Option", 1))
+	params := func(symbols SymbolList) SymbolList {
+		for _, sym := range symbols {
+			if sym.Label == "Register" {
+				return sym.FuncSpec.Params
+			}
+		}
+		t.Fatal("Register is missing")
+		return nil
+	}
+
+	for _, test := range []struct {
+		name     string
+		old, new SymbolList
+		want     string
+	}{
+		{name: "no longer variadic", old: params(variadic), new: params(slice), want: "parameter is no longer variadic, []Option instead of ...Option"},
+		{name: "now variadic", old: params(slice), new: params(variadic), want: "parameter is now variadic, ...Option instead of []Option"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			diffs, old, new := variadicChange(test.old, test.new)
+			if len(diffs) != 1 || diffs[0].Message != test.want || strings.Join(diffs[0].Path, " ") != "param 1" {
+				t.Errorf("findings are %+v, want param 1: %s", diffs, test.want)
+			}
+			// the variadic param is left out of the params compared further
+			if len(old) != 1 || len(new) != 1 {
+				t.Errorf("%d and %d params are left, want 1", len(old), len(new))
+			}
+		})
+	}
+	if diffs, old, _ := variadicChange(params(variadic), params(variadic)); diffs != nil || len(old) != 2 {
+		t.Errorf("unchanged params: findings %+v and %d params left, want none and 2", diffs, len(old))
+	}
+}
+
+func TestGroupedParamsOfOldBaselines(t *testing.T) {
+	setPolicy(t, nil)
+	// the text report is written along with the rest, as with the default -format
+	outputFormat = "text"
+	defer func() { outputFormat = "" }()
+	reference, err := filepath.Abs(filepath.Join("testdata", "baselines", "params0.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the reference was taken when only the first of params declared together was
+	// recorded: x of x, y int and w of w, h int
+	const source = `package plugin
+
+func Pos(x, y int, name string) (w, h int) {
+	return 0, 0
+}
+
+func Move(dx int, dy int) {}
+`
+	tests := []struct {
+		name   string
+		source string
+		code   int
+		output string
+	}{
+		{name: "unchanged", source: source, code: 0},
+		{
+			name:   "grouped param changed",
+			source: strings.Replace(source, "Pos(x, y int, name string)", "Pos(x, y int64, name string)", 1),
+			code:   2,
+			output: "type changed from int to int64",
+		},
+		{
+			name:   "param added to a group",
+			source: strings.Replace(source, "Pos(x, y int, name string)", "Pos(x, y, z int, name string)", 1),
+			code:   0,
+		},
+		{
+			// the reference cannot tell dx int, dy int from dx, dy int, whose dy it would
+			// not have recorded
+			name:   "params grouped",
+			source: strings.Replace(source, "Move(dx int, dy int)", "Move(dx, dy int)", 1),
+			code:   2,
+			output: "number of parameters changed from 2 to 1",
+		},
+		{
+			name:   "params ungrouped",
+			source: strings.Replace(source, "(w, h int)", "(w int, h int)", 1),
+			code:   2,
+			output: "number of results changed from 1 to 2",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := ioutil.WriteFile(filepath.Join(dir, "plugin.go"), []byte(test.source), 0644); err != nil {
+				t.Fatal(err)
+			}
+			w := new(bytes.Buffer)
+			code, verdict := evaluateChecks(w, []packageCheck{{Dir: dir, References: []string{reference}}}, false)
+			if code != test.code {
+				t.Errorf("exit code is %d (%s), want %d, report:\n%s", code, verdict, test.code, w)
+			}
+			if !strings.Contains(w.String(), test.output) {
+				t.Errorf("report does not mention %q:\n%s", test.output, w)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package exports
+
+import (
+	"fmt"
+	"go/build/constraint"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// platforms are the GOOS/GOARCH pairs the availability of symbols is evaluated on, by
+// default defaultPlatforms.
+var platforms stringList
+
+var defaultPlatforms = stringList{
+	"aix/ppc64", "android/arm64", "darwin/amd64", "darwin/arm64", "freebsd/386", "freebsd/amd64",
+	"freebsd/arm64", "illumos/amd64", "ios/arm64", "js/wasm", "linux/386", "linux/amd64",
+	"linux/arm", "linux/arm64", "linux/loong64", "linux/mips64le", "linux/ppc64le", "linux/riscv64",
+	"linux/s390x", "netbsd/amd64", "openbsd/amd64", "plan9/amd64", "solaris/amd64", "wasip1/wasm",
+	"windows/386", "windows/amd64", "windows/arm64",
+}
+
+var knownOS = map[string]bool{
+	"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true, "hurd": true,
+	"illumos": true, "ios": true, "js": true, "linux": true, "nacl": true, "netbsd": true,
+	"openbsd": true, "plan9": true, "solaris": true, "wasip1": true, "windows": true, "zos": true,
+}
+
+var unixOS = map[string]bool{
+	"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true, "hurd": true,
+	"illumos": true, "ios": true, "linux": true, "netbsd": true, "openbsd": true, "solaris": true,
+}
+
+var knownArch = map[string]bool{
+	"386": true, "amd64": true, "arm": true, "arm64": true, "loong64": true, "mips": true,
+	"mipsle": true, "mips64": true, "mips64le": true, "ppc64": true, "ppc64le": true,
+	"riscv64": true, "s390x": true, "wasm": true,
+}
+
+// platformTag reports whether a build tag is satisfied on a GOOS/GOARCH pair. Release
+// tags and the gc and cgo tags are taken as satisfied, custom tags as not.
+func platformTag(platform, tag string) bool {
+	goos, goarch, _ := strings.Cut(platform, "/")
+	switch {
+	case tag == goos || tag == goarch:
+		return true
+	case tag == "unix":
+		return unixOS[goos]
+	case tag == "linux":
+		return goos == "android"
+	case tag == "darwin":
+		return goos == "ios"
+	case tag == "solaris":
+		return goos == "illumos"
+	default:
+		return tag == "gc" || tag == "cgo" || strings.HasPrefix(tag, "go1.")
+	}
+}
+
+// builtOn reports whether a file is built on a GOOS/GOARCH pair, given its name, like
+// foo_linux_amd64.go, and its //go:build expression.
+func builtOn(platform, fileName, expr string) bool {
+	if expr != "" {
+		if parsed, err := constraint.Parse("//go:build " + expr); err == nil && !parsed.Eval(func(tag string) bool { return platformTag(platform, tag) }) {
+			return false
+		}
+	}
+	name := strings.TrimSuffix(filepath.Base(fileName), ".go")
+	parts := strings.Split(name, "_")
+	if len(parts) < 2 {
+		return true
+	}
+	last := parts[len(parts)-1]
+	if len(parts) > 2 && knownOS[parts[len(parts)-2]] && knownArch[last] {
+		return platformTag(platform, parts[len(parts)-2]) && platformTag(platform, last)
+	}
+	if knownOS[last] || knownArch[last] {
+		return platformTag(platform, last)
+	}
+	return true
+}
+
+// availability maps the idents of top level symbols to the platforms any of their
+// declarations is built on.
+func availability(symbols SymbolList) map[string]map[string]bool {
+	pairs := platforms
+	if len(pairs) == 0 {
+		pairs = defaultPlatforms
+	}
+	res := make(map[string]map[string]bool)
+	for _, sym := range symbols {
+		if sym.Unexported || sym.FileName == "" {
+			continue
+		}
+		if res[sym.Ident()] == nil {
+			res[sym.Ident()] = make(map[string]bool)
+		}
+		for _, platform := range pairs {
+			if builtOn(platform, sym.FileName, sym.Constraint) {
+				res[sym.Ident()][platform] = true
+			}
+		}
+	}
+	return res
+}
+
+// compareAvailability reports symbols built on fewer platforms than in the reference,
+// like those of a file which gained a //go:build linux constraint. As every file is
+// extracted regardless of constraints, such symbols would otherwise go unnoticed, or
+// be reported as plain removals when one of several variants was deleted.
+func compareAvailability(reference, current SymbolList, diffs []Diff) []Diff {
+	before, after := availability(reference), availability(current)
+	shrunk := make(map[string][]string)
+	for ident, built := range before {
+		if after[ident] == nil {
+			continue
+		}
+		for platform := range built {
+			if !after[ident][platform] {
+				shrunk[ident] = append(shrunk[ident], platform)
+			}
+		}
+	}
+	if len(shrunk) == 0 {
+		return diffs
+	}
+	res := make([]Diff, 0, len(diffs))
+	for _, diff := range diffs {
+		// a variant removed is reported with the platforms it was built on
+		if diff.Kind != DiffRemoved || shrunk[diff.Symbol] == nil {
+			res = append(res, diff)
+		}
+	}
+	for i := range current {
+		sym := &current[i]
+		lost := shrunk[sym.Ident()]
+		if lost == nil || sym.Unexported || sym.FileName == "" {
+			continue
+		}
+		delete(shrunk, sym.Ident())
+		sort.Strings(lost)
+		diff := changed("constraint", "no longer built on %s", strings.Join(lost, ", "))
+		diff.Symbol, diff.New = sym.Ident(), sym
+		if i := referenceIndex(reference, sym.Ident()); i >= 0 {
+			diff.Old, diff.Pointer = &reference[i], fmt.Sprintf("/%d", i)
+		}
+		res = append(res, diff)
+	}
+	return res
+}
+
+// referenceIndex is the index of the first top level symbol with the given ident.
+func referenceIndex(symbols SymbolList, ident string) int {
+	for i := range symbols {
+		if symbols[i].FileName != "" && symbols[i].Ident() == ident {
+			return i
+		}
+	}
+	return -1
+}
+
+// withoutConstraints copies symbols leaving out their //go:build expressions, for
+// references which did not record them.
+func withoutConstraints(symbols SymbolList) SymbolList {
+	res := make(SymbolList, len(symbols))
+	for i, sym := range symbols {
+		sym.Constraint = ""
+		res[i] = sym
+	}
+	return res
+}
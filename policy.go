@@ -0,0 +1,538 @@
+package exports
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Policy decides which differences make a comparison fail.
+type Policy struct {
+	// MaxNewExports is the number of new exported symbols accepted without review.
+	// A negative value disables the gate, and every new symbol fails the comparison.
+	MaxNewExports int
+	// AckNewExports acknowledges a reviewed set of new exports, see newExportsToken.
+	AckNewExports string
+	// Unfreeze lists frozen symbols whose changes are acknowledged.
+	Unfreeze stringList
+	// Hygiene lists the conventions new exports are held to, see hygieneRules.
+	Hygiene stringList
+	// InterfaceAdditions and InterfaceRemovals are the severities of methods added to and
+	// removed from interfaces. Interfaces only implemented by the package itself can
+	// accept additions, interfaces only implemented by consumers can accept removals.
+	InterfaceAdditions Severity
+	InterfaceRemovals  Severity
+	// OptionsAdditions is the severity of fields added to options structs, see optionsStructs.
+	OptionsAdditions Severity
+	// MethodAdditions is the severity of methods added to concrete types, which keep
+	// callers compiling, unlike methods added to interfaces.
+	MethodAdditions Severity
+	// Suppressed maps fingerprints of accepted findings, see diffFingerprint, to the
+	// reason they were accepted for.
+	Suppressed map[string]string
+	// RequireMajorTarget only accepts breaking findings whose reason names the
+	// major version they target, the one after the contract of the reference.
+	RequireMajorTarget bool
+	// FreezeInterfaces freezes every interface of the reference, as if taken with -freeze.
+	FreezeInterfaces bool
+	// FieldTags compares the tags of struct fields, which encoders like encoding/json depend on.
+	FieldTags bool
+	// StrictFuncs fails on every signature change, including those -typed finds compatible.
+	StrictFuncs bool
+	// AllowAdditions accepts new exported symbols and struct fields. Methods added to
+	// interfaces are still governed by InterfaceAdditions.
+	AllowAdditions bool
+	// ReportOnly reports differences without ever failing the comparison.
+	ReportOnly bool
+	// Disable lists rules, see ruleIDs, whose findings are left out. EnableOnly, if set,
+	// lists the only rules whose findings are kept.
+	Disable    stringList
+	EnableOnly stringList
+	// PlatformTypes lists the types ABI sensitive symbols are checked for, like uintptr
+	// or syscall.* for every type of a package, see checkPlatformTypes.
+	PlatformTypes stringList
+	// FailOn lists the classes of findings, see Diff.Class, that fail the comparison.
+	// Empty, the severities decide.
+	FailOn stringList
+	// MaxSurfaceGrowth and MaxSurfaceShrink limit how much the size of the API, see
+	// surfaceSize, may change since the reference, in percent. Negative values disable the limits.
+	MaxSurfaceGrowth float64
+	MaxSurfaceShrink float64
+	// ExitOn lists the rules, by ID or category, whose findings fail the comparison.
+	// It takes precedence over FailOn and the severities.
+	ExitOn stringList
+	// FreezeWindows are date ranges, like 2024-12-15..2025-01-06, during which any change
+	// of the API fails the comparison, even an addition, see checkFreezeWindows.
+	FreezeWindows stringList
+}
+
+// stringList is a flag accepting comma separated values, which may be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+func (l stringList) contains(value string) bool {
+	for _, item := range l {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether sym is listed, either by ident or, for top level symbols, by label.
+func (l stringList) matches(sym Symbol) bool {
+	for _, item := range l {
+		if item == sym.Ident() || item == strings.TrimPrefix(sym.Ident(), ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// frozen reports whether diff changes a frozen symbol without acknowledgement. Only
+// -unfreeze acknowledges such changes: neither the severity of a finding nor rules,
+// profiles or suppressions do. Moving a symbol to another file does not change it.
+func (p Policy) frozen(diff Diff) bool {
+	if diff.Old == nil || diff.Kind == DiffMoved || p.Unfreeze.matches(*diff.Old) {
+		return false
+	}
+	return diff.Old.Frozen || p.FreezeInterfaces && diff.Old.SymbolType == "interface"
+}
+
+// profiles are presets of the policy for common kinds of packages. They leave
+// settings given explicitly on the command line, listed in set, alone.
+var profiles = map[string]func(p *Policy, set map[string]bool){
+	// contract packages like plugin APIs consist of interfaces implemented on both
+	// sides, where any change breaks someone
+	"contract": func(p *Policy, set map[string]bool) {
+		p.FreezeInterfaces, p.FieldTags, p.StrictFuncs = true, true, true
+		if !set["interface-additions"] {
+			p.InterfaceAdditions = SeverityBreaking
+		}
+		if !set["interface-removals"] {
+			p.InterfaceRemovals = SeverityBreaking
+		}
+		if !set["options-additions"] {
+			p.OptionsAdditions = SeverityBreaking
+		}
+		if !set["method-additions"] {
+			p.MethodAdditions = SeverityBreaking
+		}
+	},
+	// plugin hosts grow freely, except for the interfaces plugins implement, which
+	// break plugins whenever a method is added
+	"plugin": func(p *Policy, set map[string]bool) {
+		p.AllowAdditions = true
+		if !set["interface-additions"] {
+			p.InterfaceAdditions = SeverityBreaking
+		}
+		if !set["method-additions"] {
+			p.MethodAdditions = SeverityInfo
+		}
+	},
+	// libraries may grow, but must not take anything away from their callers
+	"library": func(p *Policy, set map[string]bool) {
+		p.AllowAdditions = true
+	},
+	// internal packages only have callers in the same repository, which are
+	// updated along with them
+	"internal": func(p *Policy, set map[string]bool) {
+		p.ReportOnly = true
+	},
+}
+
+// applyProfile sets up the policy for the named profile, see profiles.
+func (p *Policy) applyProfile(name string) error {
+	apply, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %s", name)
+	}
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	apply(p, set)
+	return nil
+}
+
+var policy = DefaultOptions().Policy
+
+func validSeverity(severity Severity) bool {
+	switch severity {
+	case SeverityBreaking, SeverityWarning, SeverityInfo:
+		return true
+	}
+	return false
+}
+
+// validate checks settings given on the command line.
+func (p Policy) validate() error {
+	if !validSeverity(p.InterfaceAdditions) {
+		return fmt.Errorf("unknown severity %s for interface additions", p.InterfaceAdditions)
+	}
+	if !validSeverity(p.InterfaceRemovals) {
+		return fmt.Errorf("unknown severity %s for interface removals", p.InterfaceRemovals)
+	}
+	if !validSeverity(p.OptionsAdditions) {
+		return fmt.Errorf("unknown severity %s for options struct additions", p.OptionsAdditions)
+	}
+	if !validSeverity(p.MethodAdditions) {
+		return fmt.Errorf("unknown severity %s for method additions", p.MethodAdditions)
+	}
+	for _, class := range p.FailOn {
+		if Class(class) != ClassBreaking && Class(class) != ClassAdditive && Class(class) != ClassInformational {
+			return fmt.Errorf("unknown class %s for -fail-on, use breaking, additive or informational", class)
+		}
+	}
+	for _, name := range p.ExitOn {
+		if ruleOf(name) == "" {
+			return fmt.Errorf("unknown rule %s for -exit-on, use rule IDs like SC001 or categories like removed", name)
+		}
+	}
+	for _, name := range p.Hygiene {
+		if _, ok := hygieneRules[name]; !ok {
+			return fmt.Errorf("unknown hygiene rule %s for -hygiene, use %s", name, strings.Join(hygieneNames(), ", "))
+		}
+	}
+	for _, window := range p.FreezeWindows {
+		if _, _, err := parseFreezeWindow(window); err != nil {
+			return err
+		}
+	}
+	for _, id := range append(append(stringList{}, p.Disable...), p.EnableOnly...) {
+		if !knownRule(id) {
+			return fmt.Errorf("unknown rule %s", id)
+		}
+	}
+	return nil
+}
+
+// relaxMethodAdditions gives methods added to concrete types the severity of the policy.
+func (p Policy) relaxMethodAdditions(diffs []Diff) {
+	for i := range diffs {
+		if diffs[i].Kind == DiffAdded && diffs[i].New.SymbolType == "method" {
+			diffs[i].Severity = p.MethodAdditions
+			diffs[i].Category = "method-added"
+		}
+	}
+}
+
+// fails reports whether diff alone makes the comparison fail.
+func (p Policy) fails(diff Diff) bool {
+	if p.frozen(diff) {
+		return true
+	}
+	if p.ReportOnly {
+		return false
+	}
+	if len(p.ExitOn) > 0 {
+		return p.exitsOn(diff)
+	}
+	if len(p.FailOn) > 0 {
+		return p.FailOn.contains(string(diff.Class()))
+	}
+	if diff.Severity == SeverityWarning || diff.Severity == SeverityInfo {
+		return false
+	}
+	if isNewExport(diff) && (p.MaxNewExports >= 0 || p.AllowAdditions) {
+		return false
+	}
+	if diff.Category == "member-added" && p.AllowAdditions {
+		return false
+	}
+	return true
+}
+
+// Class is the nature of a finding, which -fail-on selects the failing ones by.
+type Class string
+
+const (
+	// ClassBreaking is a change that breaks consumers, like a removal or a changed signature
+	ClassBreaking Class = "breaking"
+	// ClassAdditive is new API: symbols, struct fields and methods of concrete types
+	ClassAdditive Class = "additive"
+	// ClassInformational is a change that does not affect consumers, or most of them
+	ClassInformational Class = "informational"
+)
+
+// Class tells whether d is breaking, additive or informational. Findings the policy
+// rates as warnings or info are informational, whatever their nature.
+func (d Diff) Class() Class {
+	switch {
+	case d.Severity == SeverityWarning || d.Severity == SeverityInfo:
+		return ClassInformational
+	case isNewExport(d) || d.Category == "member-added" || d.Category == "method-added":
+		return ClassAdditive
+	default:
+		return ClassBreaking
+	}
+}
+
+func isNewExport(diff Diff) bool {
+	return diff.Kind == DiffAdded || diff.Kind == DiffPromoted
+}
+
+// newExportsToken identifies the exact set of new exports in diffs, so that an
+// acknowledgement does not carry over to symbols added after the review.
+func newExportsToken(diffs []Diff) string {
+	idents := make([]string, 0)
+	for _, diff := range diffs {
+		if isNewExport(diff) {
+			idents = append(idents, diff.Symbol)
+		}
+	}
+	sort.Strings(idents)
+	sum := sha256.Sum256([]byte(strings.Join(idents, "\n")))
+	return hex.EncodeToString(sum[:6])
+}
+
+// checkNewExports enforces the new-export gate.
+func (p Policy) checkNewExports(diffs []Diff) error {
+	if p.MaxNewExports < 0 || p.ReportOnly || len(p.FailOn) > 0 && !p.FailOn.contains(string(ClassAdditive)) {
+		return nil
+	}
+	if len(p.ExitOn) > 0 && !p.exitsOn(Diff{Kind: DiffAdded}) {
+		return nil
+	}
+	count := 0
+	for _, diff := range diffs {
+		if isNewExport(diff) {
+			count++
+		}
+	}
+	if count <= p.MaxNewExports {
+		return nil
+	}
+	token := newExportsToken(diffs)
+	if p.AckNewExports == token {
+		return nil
+	}
+	return fmt.Errorf("%d new exported symbols exceed the limit of %d, review them and rerun with -ack-new-exports %s", count, p.MaxNewExports, token)
+}
+
+// checkFrozen lists frozen symbols changed without acknowledgement.
+func (p Policy) checkFrozen(diffs []Diff) error {
+	idents := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, diff := range diffs {
+		if p.frozen(diff) && !seen[diff.Old.Ident()] {
+			seen[diff.Old.Ident()] = true
+			idents = append(idents, strings.TrimPrefix(diff.Old.Ident(), "."))
+		}
+	}
+	if len(idents) == 0 {
+		return nil
+	}
+	return fmt.Errorf("frozen symbols changed, acknowledge with -unfreeze %s", strings.Join(idents, ","))
+}
+
+// parseFreezeWindow returns the first and last day of a freeze window, given as
+// first..last or as a single day, both as 2006-01-02.
+func parseFreezeWindow(window string) (string, string, error) {
+	first, last, ok := strings.Cut(window, "..")
+	if !ok {
+		last = first
+	}
+	for _, day := range []string{first, last} {
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			return "", "", fmt.Errorf("freeze window must be like 2024-12-15..2025-01-06, got %s", window)
+		}
+	}
+	if last < first {
+		return "", "", fmt.Errorf("freeze window %s ends before it starts", window)
+	}
+	return first, last, nil
+}
+
+// checkFreezeWindows fails any change of the API on a day within a freeze window. Moves
+// and findings about the current code alone, like build variants, change nothing.
+// Suppressed findings are accepted exceptions, and do not fail either.
+func (p Policy) checkFreezeWindows(diffs []Diff, now time.Time) error {
+	if p.ReportOnly {
+		return nil
+	}
+	today := now.Format("2006-01-02")
+	for _, window := range p.FreezeWindows {
+		first, last, _ := parseFreezeWindow(window)
+		if today < first || today > last {
+			continue
+		}
+		changes := 0
+		for _, diff := range diffs {
+			switch diff.Kind {
+			case DiffMoved, DiffInconsistent, DiffHygiene:
+			default:
+				changes++
+			}
+		}
+		if changes > 0 {
+			return fmt.Errorf("the API is frozen from %s to %s, but %d changes were found", first, last, changes)
+		}
+	}
+	return nil
+}
+
+// defaultSuppressFile is read as if passed with -suppress when it is in the working directory.
+const defaultSuppressFile = ".symbolcheck-ignore"
+
+// fingerprintPattern matches finding fingerprints, see diffFingerprint.
+var fingerprintPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// symbolSuppression splits a suppression accepting the findings of symbols matching a
+// pattern, like Legacy* or, for the findings of one rule only, SC001:Legacy*. Fingerprints
+// and rule IDs are not symbol suppressions.
+func symbolSuppression(key string) (rule, pattern string, ok bool) {
+	if fingerprintPattern.MatchString(key) || rulePattern.MatchString(key) {
+		return "", "", false
+	}
+	if rule, pattern, found := strings.Cut(key, ":"); found && rulePattern.MatchString(rule) {
+		return rule, pattern, true
+	}
+	return "", key, true
+}
+
+// readSuppressions reads a file of finding fingerprints, one per line, written with or
+// without the # they are printed with, rule IDs or symbol patterns, see symbolSuppression.
+// Anything after them is the reason they were accepted. Lines starting with "# " are ignored.
+func readSuppressions(fileName string) (map[string]string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	res := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line == "#" || strings.HasPrefix(line, "# ") {
+			continue
+		}
+		fields := strings.Fields(line)
+		key := strings.TrimPrefix(fields[0], "#")
+		if rule, pattern, ok := symbolSuppression(key); ok {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("%s: bad symbol pattern %s: %v", fileName, pattern, err)
+			}
+			if rule != "" && !knownRule(rule) {
+				return nil, fmt.Errorf("%s: unknown rule %s", fileName, rule)
+			}
+		}
+		res[key] = strings.Join(fields[1:], " ")
+	}
+	return res, scanner.Err()
+}
+
+// majorTargetPattern matches any major version a reason may name when the contract
+// of the reference is unknown.
+var majorTargetPattern = regexp.MustCompile(`\bv\d+\b`)
+
+// namesTarget reports whether reason names the major version a breaking change
+// against a reference with meta targets.
+func namesTarget(reason string, meta *BaselineMeta) bool {
+	if next := meta.nextMajor(); next != "" {
+		return regexp.MustCompile(`\b` + next + `\b`).MatchString(reason)
+	}
+	return majorTargetPattern.MatchString(reason)
+}
+
+// suppression returns the suppression accepting diff with its reason: its fingerprint,
+// its rule, or the first pattern in order matching its symbol.
+func (p Policy) suppression(diff Diff) (string, string, bool) {
+	for _, key := range []string{diffFingerprint(diff), diff.Rule()} {
+		if reason, ok := p.Suppressed[key]; ok {
+			return key, reason, true
+		}
+	}
+	keys := make([]string, 0, len(p.Suppressed))
+	for key := range p.Suppressed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	name := strings.TrimPrefix(diff.Symbol, ".")
+	for _, key := range keys {
+		rule, pattern, ok := symbolSuppression(key)
+		if !ok || rule != "" && rule != diff.Rule() {
+			continue
+		}
+		if matched, _ := path.Match(pattern, name); matched {
+			return key, p.Suppressed[key], true
+		}
+	}
+	return "", "", false
+}
+
+// suppressed reports whether diff is accepted, see suppression.
+func (p Policy) suppressed(diff Diff) bool {
+	_, _, ok := p.suppression(diff)
+	return ok
+}
+
+// suppress drops accepted findings from diffs against the reference with meta, recording
+// the suppressions used in used. Suppressions that cannot be used are explained in the result.
+func (p Policy) suppress(diffs []Diff, used map[string]bool, meta *BaselineMeta) ([]Diff, []string) {
+	res := make([]Diff, 0, len(diffs))
+	problems := make([]string, 0)
+	for _, diff := range diffs {
+		// a fingerprint suppresses a single finding, a rule ID every finding of the rule
+		// and a pattern every finding of the symbols it matches
+		key, reason, ok := p.suppression(diff)
+		if ok && p.frozen(diff) {
+			used[key] = true
+			problems = append(problems, fmt.Sprintf("suppression %s cannot accept a change of frozen %s, acknowledge it with -unfreeze", suppressionName(key), strings.TrimPrefix(diff.Old.Ident(), ".")))
+		} else if ok && p.RequireMajorTarget && p.fails(diff) && !namesTarget(reason, meta) {
+			used[key] = true
+			target := "the major version it targets"
+			if next := meta.nextMajor(); next != "" {
+				target = next
+			}
+			problems = append(problems, fmt.Sprintf("suppression %s of a breaking finding must name %s", suppressionName(key), target))
+		} else if ok {
+			tracef("policy: %s %s suppressed by %s", diff.Symbol, diff.category(), suppressionName(key))
+			used[key] = true
+			continue
+		}
+		res = append(res, diff)
+	}
+	return res, problems
+}
+
+// suppressionName shows a suppression as it is written in the file: fingerprints with
+// a leading #, rule IDs and patterns as they are.
+func suppressionName(key string) string {
+	if fingerprintPattern.MatchString(key) {
+		return "#" + key
+	}
+	return key
+}
+
+// staleSuppressions lists the suppressions no finding matched any more, which can be removed from the file.
+func (p Policy) staleSuppressions(used map[string]bool) []string {
+	stale := make([]string, 0)
+	for fingerprint := range p.Suppressed {
+		if !used[fingerprint] {
+			stale = append(stale, fingerprint)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
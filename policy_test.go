@@ -0,0 +1,228 @@
+package exports
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSuppress(t *testing.T) {
+	setPolicy(t, nil)
+	diffs := compare(extractSource(t, t.TempDir(), formattingSource), extractSource(t, t.TempDir(), findingsSource))
+	all := diffSummary(diffs)
+	if want := "added .Register, changed .New, changed .Plugin, removed Config.Validate"; all != want {
+		t.Fatalf("findings are %s, want %s", all, want)
+	}
+
+	tests := []struct {
+		name string
+		// file is the content of the suppressions file
+		file string
+		// want are the findings left
+		want  string
+		used  string
+		stale string
+	}{
+		{name: "none", file: "", want: all},
+		{
+			name: "fingerprint",
+			file: "#c9c5aaadd4d7b525 size is always small\n",
+			want: "added .Register, changed .Plugin, removed Config.Validate",
+			used: "c9c5aaadd4d7b525",
+		},
+		{
+			name: "fingerprint without #",
+			file: "c9c5aaadd4d7b525\n",
+			want: "added .Register, changed .Plugin, removed Config.Validate",
+			used: "c9c5aaadd4d7b525",
+		},
+		{
+			name: "rule",
+			file: "SC001 validation moved to New\nSC009\n",
+			want: "added .Register, changed .New",
+			used: "SC001 SC009",
+		},
+		{
+			name: "symbol pattern",
+			file: "Config.* validation moved to New\nRegister\n",
+			want: "changed .New, changed .Plugin",
+			used: "Config.* Register",
+		},
+		{
+			// the pattern only suppresses findings of its rule
+			name:  "symbol pattern of a rule",
+			file:  "SC002:Reg* registration\nSC006:Reg*\n",
+			want:  "changed .New, changed .Plugin, removed Config.Validate",
+			used:  "SC002:Reg*",
+			stale: "SC006:Reg*",
+		},
+		{
+			name:  "stale",
+			file:  "# accepted for v2\n#0123456789abcdef a finding fixed since\nLegacy*\nSC017\nSC001\n",
+			want:  "added .Register, changed .New, changed .Plugin",
+			used:  "SC001",
+			stale: "0123456789abcdef Legacy* SC017",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), defaultSuppressFile)
+			if err := ioutil.WriteFile(fileName, []byte(test.file), 0644); err != nil {
+				t.Fatal(err)
+			}
+			suppressed, err := readSuppressions(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			setPolicy(t, func(p *Policy) { p.Suppressed = suppressed })
+
+			used := make(map[string]bool)
+			left, problems := policy.suppress(diffs, used, nil)
+			if len(problems) > 0 {
+				t.Errorf("problems: %v", problems)
+			}
+			if got := diffSummary(left); got != test.want {
+				t.Errorf("findings left are %s, want %s", got, test.want)
+			}
+			usedKeys := make([]string, 0, len(used))
+			for key := range used {
+				usedKeys = append(usedKeys, key)
+			}
+			sort.Strings(usedKeys)
+			if got := strings.Join(usedKeys, " "); got != test.used {
+				t.Errorf("used suppressions are %s, want %s", got, test.used)
+			}
+			if got := strings.Join(policy.staleSuppressions(used), " "); got != test.stale {
+				t.Errorf("stale suppressions are %s, want %s", got, test.stale)
+			}
+		})
+	}
+}
+
+// profileNames are the profiles the policy tests run under, default being none.
+var profileNames = []string{"default", "contract", "plugin", "library", "internal"}
+
+// profilePolicy sets up the policy of the named profile.
+func profilePolicy(t *testing.T, name string) Policy {
+	t.Helper()
+	p := DefaultOptions().Policy
+	if name != "default" {
+		if err := p.applyProfile(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p
+}
+
+func TestPolicyFailsProfiles(t *testing.T) {
+	fn := &Symbol{Label: "New", SymbolType: "func"}
+	unexported := &Symbol{Label: "New", SymbolType: "func", Unexported: true}
+	iface := &Symbol{Label: "Plugin", SymbolType: "interface"}
+	typ := &Symbol{Label: "Config", SymbolType: "struct"}
+	frozen := &Symbol{Label: "Config", SymbolType: "struct", Frozen: true}
+	tests := []struct {
+		name string
+		diff Diff
+		// fails lists the profiles the finding fails under
+		fails string
+	}{
+		{name: "added", diff: Diff{Kind: DiffAdded, Severity: SeverityBreaking, New: fn}, fails: "default contract"},
+		{name: "promoted", diff: Diff{Kind: DiffPromoted, Severity: SeverityBreaking, Old: unexported, New: fn}, fails: "default contract"},
+		{name: "removed", diff: Diff{Kind: DiffRemoved, Severity: SeverityBreaking, Old: fn}, fails: "default contract plugin library"},
+		{name: "removed interface", diff: Diff{Kind: DiffRemoved, Severity: SeverityBreaking, Old: iface}, fails: "default contract plugin library"},
+		{name: "changed", diff: Diff{Kind: DiffChanged, Severity: SeverityBreaking, Old: fn, New: fn}, fails: "default contract plugin library"},
+		{
+			name:  "changed member added",
+			diff:  Diff{Kind: DiffChanged, Severity: SeverityBreaking, Category: "member-added", Old: typ, New: typ},
+			fails: "default contract",
+		},
+		{
+			// interfaces are frozen in contract packages, whatever the severity
+			name:  "changed interface warning",
+			diff:  Diff{Kind: DiffChanged, Severity: SeverityWarning, Old: iface, New: iface},
+			fails: "contract",
+		},
+		{name: "changed info", diff: Diff{Kind: DiffChanged, Severity: SeverityInfo, Old: fn, New: fn}, fails: ""},
+		{name: "renamed", diff: Diff{Kind: DiffRenamed, Severity: SeverityBreaking, Old: typ, New: typ}, fails: "default contract plugin library"},
+		{name: "hygiene", diff: Diff{Kind: DiffHygiene, Severity: SeverityBreaking, New: fn}, fails: "default contract plugin library"},
+		{name: "moved", diff: Diff{Kind: DiffMoved, Severity: SeverityInfo, Old: iface, New: iface}, fails: ""},
+		{name: "inconsistent", diff: Diff{Kind: DiffInconsistent, Severity: SeverityWarning, New: fn}, fails: ""},
+		{name: "platform", diff: Diff{Kind: DiffPlatform, Severity: SeverityWarning, New: fn}, fails: ""},
+		{
+			// no profile accepts a change of a frozen symbol, not even internal
+			name:  "changed frozen",
+			diff:  Diff{Kind: DiffChanged, Severity: SeverityInfo, Old: frozen, New: typ},
+			fails: "default contract plugin library internal",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			failing := make([]string, 0)
+			for _, name := range profileNames {
+				if profilePolicy(t, name).fails(test.diff) {
+					failing = append(failing, name)
+				}
+			}
+			if got := strings.Join(failing, " "); got != test.fails {
+				t.Errorf("fails under %q, want %q", got, test.fails)
+			}
+		})
+	}
+}
+
+func TestPolicyFailsProfilesCompare(t *testing.T) {
+	// profiles also rate findings while comparing, which fails then judges
+	tests := []struct {
+		name    string
+		current string
+		fails   string
+	}{
+		{
+			name:    "interface method added",
+			current: strings.Replace(formattingSource, "Disable() error\n", "Disable() error\n\tReload() error\n", 1),
+			fails:   "default contract plugin library",
+		},
+		{
+			name:    "method added",
+			current: formattingSource + "\nfunc (c *Config) Clone() *Config {\n\treturn nil\n}\n",
+			fails:   "contract",
+		},
+		{
+			name:    "field tag changed",
+			current: strings.Replace(formattingSource, "`json:\"name\"`", "`json:\"id\"`", 1),
+			fails:   "contract",
+		},
+		{
+			name:    "field added",
+			current: strings.Replace(formattingSource, "Options map[string][]string\n", "Options map[string][]string\n\tDebug   bool\n", 1),
+			fails:   "default contract",
+		},
+		{
+			name:    "param changed",
+			current: strings.Replace(formattingSource, "size int)", "size int64)", 1),
+			fails:   "default contract plugin library",
+		},
+	}
+	reference := extractSource(t, t.TempDir(), formattingSource)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			current := extractSource(t, t.TempDir(), test.current)
+			failing := make([]string, 0)
+			for _, name := range profileNames {
+				options := DefaultOptions()
+				options.Policy = profilePolicy(t, name)
+				for _, diff := range Compare(reference, current, options) {
+					if options.Policy.fails(diff) {
+						failing = append(failing, name)
+						break
+					}
+				}
+			}
+			if got := strings.Join(failing, " "); got != test.fails {
+				t.Errorf("fails under %q, want %q", got, test.fails)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package exports
+
+import (
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+var cpuProfile string
+var memProfile string
+var traceFile string
+
+// stopProfiling writes out the profiles started by startProfiling. It runs on every
+// exit, as most of them go through os.Exit.
+var stopProfiling = func() {}
+
+func startProfiling() error {
+	stops := make([]func(), 0)
+	if cpuProfile != "" {
+		file, err := os.Create(cpuProfile)
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(file); err != nil {
+			file.Close()
+			return err
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			file.Close()
+		})
+	}
+	if traceFile != "" {
+		file, err := os.Create(traceFile)
+		if err != nil {
+			return err
+		}
+		if err := trace.Start(file); err != nil {
+			file.Close()
+			return err
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			file.Close()
+		})
+	}
+	if memProfile != "" {
+		stops = append(stops, func() {
+			file, err := os.Create(memProfile)
+			if err != nil {
+				return
+			}
+			defer file.Close()
+			runtime.GC()
+			pprof.WriteHeapProfile(file)
+		})
+	}
+	stopProfiling = func() {
+		for _, stop := range stops {
+			stop()
+		}
+		stops = nil
+	}
+	return nil
+}
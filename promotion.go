@@ -0,0 +1,143 @@
+package exports
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// embeddedTypeName is the name of the package level type an embedded field refers to,
+// or "" for types of other packages, which are not known.
+func embeddedTypeName(sym Symbol) string {
+	name := strings.TrimPrefix(embeddedType(sym), "*")
+	if i := strings.Index(name, "["); i >= 0 {
+		name = name[:i]
+	}
+	if strings.Contains(name, ".") {
+		return ""
+	}
+	return name
+}
+
+// promotedNames lists the fields and methods promoted to each struct through its
+// embedded fields, by the name they are selected with. As in Go, a name found at a
+// shallower depth hides deeper ones, and a name found twice at the same depth is
+// ambiguous and cannot be selected.
+func promotedNames(symbols SymbolList) map[string]map[string]bool {
+	structs := make(map[string]*Symbol)
+	methods := make(map[string][]string)
+	for i := range symbols {
+		sym := &symbols[i]
+		switch {
+		case sym.SymbolType == "struct" && sym.ReceiverType == "":
+			structs[sym.Label] = sym
+		case sym.SymbolType == "method":
+			methods[sym.ReceiverType] = append(methods[sym.ReceiverType], sym.Label)
+		}
+	}
+	// selectable lists the names declared directly on a type: its fields and methods
+	selectable := func(name string) []string {
+		res := append([]string(nil), methods[name]...)
+		if sym, ok := structs[name]; ok {
+			for _, member := range sym.Members {
+				res = append(res, member.Label)
+			}
+		}
+		return res
+	}
+
+	res := make(map[string]map[string]bool)
+	for name, sym := range structs {
+		hidden := make(map[string]bool)
+		for _, direct := range selectable(name) {
+			hidden[direct] = true
+		}
+		promoted := make(map[string]bool)
+		visited := map[string]bool{name: true}
+		level := []*Symbol{sym}
+		for len(level) > 0 {
+			found := make(map[string]int)
+			next := make([]*Symbol, 0)
+			for _, outer := range level {
+				for _, member := range outer.Members {
+					embedded := embeddedTypeName(member)
+					if member.SymbolType != "embed" || embedded == "" || visited[embedded] {
+						continue
+					}
+					visited[embedded] = true
+					for _, name := range selectable(embedded) {
+						found[name]++
+					}
+					if inner, ok := structs[embedded]; ok {
+						next = append(next, inner)
+					}
+				}
+			}
+			for name, count := range found {
+				if !hidden[name] && count == 1 {
+					promoted[name] = true
+				}
+				hidden[name] = true
+			}
+			level = next
+		}
+		res[name] = promoted
+	}
+	return res
+}
+
+// comparePromotions reports fields and methods that were promoted to a struct of the
+// reference and can no longer be selected on it, like cfg.Timeout once Config stops
+// embedding HTTPConfig. Names now declared on the struct itself are still selectable.
+func comparePromotions(reference, current SymbolList) []Diff {
+	refPromoted, curPromoted := promotedNames(reference), promotedNames(current)
+	curDirect := make(map[string]map[string]bool)
+	for _, sym := range current {
+		if sym.SymbolType == "method" {
+			if curDirect[sym.ReceiverType] == nil {
+				curDirect[sym.ReceiverType] = make(map[string]bool)
+			}
+			curDirect[sym.ReceiverType][sym.Label] = true
+		}
+	}
+	res := make([]Diff, 0)
+	for i := range reference {
+		old := &reference[i]
+		if old.SymbolType != "struct" || old.Unexported || old.ReceiverType != "" {
+			continue
+		}
+		var new *Symbol
+		for j := range current {
+			if current[j].SymbolType == "struct" && current[j].ReceiverType == "" && current[j].Label == old.Label {
+				new = &current[j]
+			}
+		}
+		if new == nil {
+			continue
+		}
+		fields := make(map[string]bool)
+		for _, member := range new.Members {
+			fields[member.Label] = true
+		}
+		lost := make([]string, 0)
+		for name := range refPromoted[old.Label] {
+			if !curPromoted[old.Label][name] && !fields[name] && !curDirect[old.Label][name] {
+				lost = append(lost, name)
+			}
+		}
+		sort.Strings(lost)
+		for _, name := range lost {
+			res = append(res, Diff{
+				Kind:     DiffChanged,
+				Symbol:   old.Ident(),
+				Message:  fmt.Sprintf("promoted %s can no longer be selected, selectors like x.%s break", name, name),
+				Severity: SeverityBreaking,
+				Old:      old,
+				New:      new,
+				Category: "promotion",
+				Pointer:  fmt.Sprintf("/%d", i),
+			})
+		}
+	}
+	return res
+}
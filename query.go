@@ -0,0 +1,87 @@
+package exports
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// queryName is the name symbols are queried by, like Plugin.Start for a method
+// and New for a function.
+func queryName(sym Symbol) string {
+	return strings.TrimPrefix(sym.Ident(), ".")
+}
+
+// querySymbols returns the symbols whose name matches any of the patterns, which use
+// the syntax of path.Match, or every symbol without patterns.
+func querySymbols(symbols SymbolList, patterns []string) (SymbolList, error) {
+	res := make(SymbolList, 0)
+	for _, sym := range symbols {
+		matched := len(patterns) == 0
+		for _, pattern := range patterns {
+			ok, err := path.Match(pattern, queryName(sym))
+			if err != nil {
+				return nil, fmt.Errorf("bad pattern %s: %v", pattern, err)
+			}
+			matched = matched || ok
+		}
+		if matched {
+			res = append(res, sym)
+		}
+	}
+	return res, nil
+}
+
+// runQuery prints the symbols of a snapshot matching patterns like Plugin.*, with
+// everything recorded about them.
+func runQuery(args []string) {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: symbol-check query [-json] snapshot [pattern...]")
+		flags.PrintDefaults()
+	}
+	asJSON := flags.Bool("json", false, "print the matching symbols as one JSON array")
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		exitWithStatusString("query: a snapshot is required", 1)
+	}
+
+	refData, err := loadReference(flags.Arg(0))
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	symbols, err := querySymbols(refData.Symbols, flags.Args()[1:])
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	if len(symbols) == 0 {
+		exitWithStatusString("no symbols match", 1)
+	}
+	if *asJSON {
+		out, err := json.MarshalIndent(symbols, "", "  ")
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+	for i, sym := range symbols {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s %s", sym.SymbolType, queryName(sym))
+		if sym.FileName != "" {
+			fmt.Printf(" in %s", sym.FileName)
+		}
+		fmt.Println()
+		out, err := json.MarshalIndent(sym, "", "  ")
+		if err != nil {
+			panic(err)
+		}
+		os.Stdout.Write(append(out, '\n'))
+	}
+}
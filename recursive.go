@@ -0,0 +1,180 @@
+package exports
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// recursive snapshots or compares every package of the module below the work dir at
+// once, see modulePackages.
+var recursive bool
+
+var modulePattern = regexp.MustCompile(`(?m)^module\s+"?([^\s"]+)"?`)
+
+// findModule returns the path and root directory of the module dir belongs to.
+func findModule(dir string) (string, string, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	for {
+		data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+		if err == nil {
+			match := modulePattern.FindSubmatch(data)
+			if match == nil {
+				return "", "", fmt.Errorf("no module path in %s", filepath.Join(root, "go.mod"))
+			}
+			return string(match[1]), root, nil
+		}
+		parent := filepath.Dir(root)
+		if parent == root {
+			return "", "", fmt.Errorf("%s is not part of a module", dir)
+		}
+		root = parent
+	}
+}
+
+// modulePackages lists the packages below dir which make up the public API of its
+// module, by import path. Commands, internal packages, test data, vendored code and
+// nested modules are left out.
+func modulePackages(dir string) ([]packageCheck, error) {
+	modPath, root, err := findModule(dir)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]packageCheck, 0)
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return err
+		}
+		if path != dir {
+			name := entry.Name()
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" || name == "internal" {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+		}
+		pkgs, err := parser.ParseDir(token.NewFileSet(), path, sourceFilter(path), parser.PackageClauseOnly)
+		if err != nil {
+			return err
+		}
+		delete(pkgs, "main")
+		if len(pkgs) > 1 {
+			return fmt.Errorf("multiple packages in %s", path)
+		}
+		for name := range pkgs {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, abs)
+			if err != nil {
+				return err
+			}
+			check := packageCheck{Dir: path, PkgName: name, ImportPath: modPath}
+			if rel != "." {
+				check.ImportPath += "/" + filepath.ToSlash(rel)
+			}
+			res = append(res, check)
+		}
+		return nil
+	})
+	return res, err
+}
+
+// pointerEscaper escapes a key for use in a JSON Pointer.
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// splitPackageReference splits a reference to a package of a module snapshot,
+// file#import/path, into its parts.
+func splitPackageReference(reference string) (string, string, bool) {
+	i := strings.LastIndex(reference, "#")
+	if i < 0 {
+		return reference, "", false
+	}
+	return reference[:i], reference[i+1:], true
+}
+
+// modulePackageChange reports a package that is only in the module snapshot or only
+// in the current module. Such packages are not compared any further.
+func modulePackageChange(check packageCheck) *packageResult {
+	res := &packageResult{Check: check, Compatible: true, Used: make(map[string]bool)}
+	diff := Diff{Kind: DiffAdded, Symbol: check.ImportPath, Message: "package " + check.ImportPath, Severity: SeverityBreaking, Pointer: "/packages/" + pointerEscaper.Replace(check.ImportPath)}
+	if check.Gone {
+		diff.Kind, diff.Message, diff.Category = DiffRemoved, "package "+check.ImportPath+" removed or moved", "package"
+	}
+	diffs := rateForTier(tierOf(check), policy.enabledDiffs([]Diff{diff}))
+	if key, _, ok := policy.suppression(diff); len(diffs) > 0 && ok {
+		res.Used[key] = true
+		fmt.Fprintln(&res.Output, "1 accepted findings suppressed")
+		diffs = nil
+	}
+	if inlineText() {
+		printDiffSections(&res.Output, diffs)
+	}
+	for _, diff := range diffs {
+		res.Compatible = res.Compatible && !policy.fails(diff)
+	}
+	res.Diffs = diffs
+	return res
+}
+
+// runRecursive snapshots every package of the module below the work dir into a single
+// snapshot keyed by import path, or compares them against such a snapshot.
+func runRecursive() {
+	checks, err := modulePackages(workDir)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	if len(compareTo) == 0 {
+		baseline := &Baseline{Packages: make(map[string]*Baseline)}
+		for _, check := range checks {
+			symbols, err := snapshotSymbols(check.Dir, check.PkgName)
+			if err != nil {
+				exitWithStatusError(err, 1)
+			}
+			pkg := &Baseline{Symbols: symbols}
+			if identity {
+				if pkg.Package, err = packageIdentity(check.Dir, check.PkgName); err != nil {
+					exitWithStatusError(err, 1)
+				}
+			}
+			baseline.Packages[check.ImportPath] = pkg
+		}
+		writeSnapshot(baseline)
+		return
+	}
+	if len(compareTo) > 1 || baselineStore != nil {
+		exitWithStatusString("-r compares against a single module snapshot file", 1)
+	}
+	refData, err := loadReference(compareTo[0])
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	if refData.Packages == nil {
+		exitWithStatusString(compareTo[0]+" is not a module snapshot, take one with -r", 1)
+	}
+	current := make(map[string]bool)
+	for i := range checks {
+		current[checks[i].ImportPath] = true
+		if refData.Packages[checks[i].ImportPath] != nil {
+			checks[i].References = []string{compareTo[0] + "#" + checks[i].ImportPath}
+		}
+	}
+	for importPath := range refData.Packages {
+		if !current[importPath] {
+			checks = append(checks, packageCheck{ImportPath: importPath, Gone: true})
+		}
+	}
+	sort.Slice(checks, func(i, j int) bool { return checks[i].String() < checks[j].String() })
+	runChecks(checks, true)
+}
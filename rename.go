@@ -0,0 +1,139 @@
+package exports
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// symbolPointer is the JSON Pointer of sym within symbols, relative to the list.
+func symbolPointer(symbols SymbolList, sym *Symbol) string {
+	for i := range symbols {
+		if &symbols[i] == sym {
+			return fmt.Sprintf("/%d", i)
+		}
+	}
+	return ""
+}
+
+// methodSets maps receiver types to their methods, and type names to their declarations.
+func methodSets(symbols SymbolList) (map[string]map[string]*Symbol, map[string]*Symbol) {
+	methods := make(map[string]map[string]*Symbol)
+	decls := make(map[string]*Symbol)
+	for i := range symbols {
+		sym := &symbols[i]
+		switch {
+		case sym.Unexported:
+		case sym.SymbolType == "method":
+			if methods[sym.ReceiverType] == nil {
+				methods[sym.ReceiverType] = make(map[string]*Symbol)
+			}
+			methods[sym.ReceiverType][sym.Label] = sym
+		case sym.SymbolType != "func" && sym.SymbolType != "var" && sym.SymbolType != "const":
+			decls[sym.Label] = sym
+		}
+	}
+	return methods, decls
+}
+
+func methodNames(methods map[string]*Symbol) string {
+	names := make([]string, 0, len(methods))
+	for name := range methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// correlateTypeRenames collapses a receiver type whose whole method set moved to a
+// new name into a single renamed finding. Instead of reporting every method as
+// removed and added, only the differences between the old and new declarations
+// and methods are reported.
+func (c comparer) correlateTypeRenames(reference, current SymbolList, diffs []Diff) []Diff {
+	refMethods, refDecls := methodSets(reference)
+	curMethods, curDecls := methodSets(current)
+
+	// receivers are candidates when they exist in only one of the trees
+	candidates := make(map[string][]string)
+	for recv, methods := range curMethods {
+		if _, ok := refMethods[recv]; !ok {
+			candidates[methodNames(methods)] = append(candidates[methodNames(methods)], recv)
+		}
+	}
+	renames := make(map[string]string)
+	claimed := make(map[string]int)
+	for recv, methods := range refMethods {
+		if _, ok := curMethods[recv]; ok {
+			continue
+		}
+		if news := candidates[methodNames(methods)]; len(news) == 1 {
+			renames[recv] = news[0]
+			claimed[news[0]]++
+		}
+	}
+	for old, new := range renames {
+		if claimed[new] > 1 {
+			delete(renames, old)
+		}
+	}
+	if len(renames) == 0 {
+		return diffs
+	}
+
+	renamedTo := make(map[string]bool)
+	for _, new := range renames {
+		renamedTo[new] = true
+	}
+	res := make([]Diff, 0, len(diffs))
+	for _, diff := range diffs {
+		if diff.Kind == DiffRemoved && (renames[diff.Old.ReceiverType] != "" || (diff.Old.ReceiverType == "" && renames[diff.Old.Label] != "")) {
+			continue
+		}
+		if diff.Kind == DiffAdded && (renamedTo[diff.New.ReceiverType] || (diff.New.ReceiverType == "" && renamedTo[diff.New.Label])) {
+			continue
+		}
+		res = append(res, diff)
+	}
+
+	olds := make([]string, 0, len(renames))
+	for old := range renames {
+		olds = append(olds, old)
+	}
+	sort.Strings(olds)
+	for _, old := range olds {
+		new := renames[old]
+		oldDecl, newDecl := refDecls[old], curDecls[new]
+		res = append(res, Diff{
+			Kind:     DiffRenamed,
+			Symbol:   "." + old,
+			Message:  fmt.Sprintf("type renamed to %s with its %d methods", new, len(refMethods[old])),
+			Severity: SeverityBreaking,
+			Old:      oldDecl,
+			New:      newDecl,
+			Pointer:  symbolPointer(reference, oldDecl),
+		})
+		if oldDecl != nil && newDecl != nil {
+			for _, diff := range c.compareSymbol(*oldDecl, *newDecl, false) {
+				diff.Symbol, diff.Old, diff.New = newDecl.Ident(), oldDecl, newDecl
+				diff.Pointer = symbolPointer(reference, oldDecl) + diff.Pointer
+				res = append(res, diff)
+			}
+		}
+		labels := make([]string, 0, len(refMethods[old]))
+		for label := range refMethods[old] {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		for _, label := range labels {
+			oldMethod, newMethod := refMethods[old][label], curMethods[new][label]
+			moved := *newMethod
+			moved.ReceiverType = old
+			for _, diff := range c.compareSymbol(*oldMethod, moved, true) {
+				diff.Symbol, diff.Old, diff.New = newMethod.Ident(), oldMethod, newMethod
+				diff.Pointer = symbolPointer(reference, oldMethod) + diff.Pointer
+				res = append(res, diff)
+			}
+		}
+	}
+	return res
+}
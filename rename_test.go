@@ -0,0 +1,116 @@
+package exports
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+// renameSource is a package with a type and its methods, which the rename tests move.
+const renameSource = `package plugin
+
+type Client struct {
+	Name string
+}
+
+func (c *Client) Get(key string) string {
+	return ""
+}
+
+func (c *Client) Close() error {
+	return nil
+}
+`
+
+// diffSummary lists the kind and symbol of every difference, sorted.
+func diffSummary(diffs []Diff) string {
+	res := make([]string, len(diffs))
+	for i, diff := range diffs {
+		res[i] = string(diff.Kind) + " " + diff.Symbol
+	}
+	sort.Strings(res)
+	return strings.Join(res, ", ")
+}
+
+func TestCorrelateTypeRenames(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference string
+		current   string
+		want      string
+		// message is that of the renamed finding, if any
+		message string
+	}{
+		{
+			name:      "renamed",
+			reference: renameSource,
+			current:   strings.Replace(renameSource, "Client", "Conn", -1),
+			want:      "renamed .Client",
+			message:   "type renamed to Conn with its 2 methods",
+		},
+		{
+			name:      "renamed with changed methods",
+			reference: renameSource,
+			current:   strings.Replace(strings.Replace(renameSource, "Client", "Conn", -1), "Get(key string)", "Get(key int)", 1),
+			want:      "changed Conn.Get, renamed .Client",
+			message:   "type renamed to Conn with its 2 methods",
+		},
+		{
+			name:      "renamed with a changed declaration",
+			reference: renameSource,
+			current:   strings.Replace(strings.Replace(renameSource, "Client", "Conn", -1), "Name string", "Name []byte", 1),
+			want:      "changed .Conn, renamed .Client",
+			message:   "type renamed to Conn with its 2 methods",
+		},
+		{
+			// either type could be the new Client, so neither is taken for it
+			name:      "two candidates",
+			reference: renameSource,
+			current:   strings.Replace(renameSource, "Client", "Conn", -1) + strings.Replace(strings.Replace(renameSource, "Client", "Session", -1), "package plugin\n", "", 1),
+			want: "added .Conn, added .Session, added Conn.Close, added Conn.Get, added Session.Close, added Session.Get, " +
+				"removed .Client, removed Client.Close, removed Client.Get",
+		},
+		{
+			// the new type could be either of the old ones
+			name:      "two renamed to one",
+			reference: renameSource + strings.Replace(strings.Replace(renameSource, "Client", "Pool", -1), "package plugin\n", "", 1),
+			current:   strings.Replace(renameSource, "Client", "Conn", -1),
+			want: "added .Conn, added Conn.Close, added Conn.Get, " +
+				"removed .Client, removed .Pool, removed Client.Close, removed Client.Get, removed Pool.Close, removed Pool.Get",
+		},
+		{
+			name:      "removed",
+			reference: renameSource,
+			current:   "package plugin\n",
+			want:      "removed .Client, removed Client.Close, removed Client.Get",
+		},
+		{
+			// a type keeping only some of its methods is not renamed
+			name:      "renamed with a removed method",
+			reference: renameSource,
+			current:   strings.Replace(strings.Replace(renameSource, "Client", "Conn", -1), "func (c *Conn) Close() error {\n\treturn nil\n}\n", "", 1),
+			want:      "added .Conn, added Conn.Get, removed .Client, removed Client.Close, removed Client.Get",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reference := extractSource(t, t.TempDir(), test.reference)
+			current := extractSource(t, t.TempDir(), test.current)
+			diffs := Compare(reference, current, DefaultOptions())
+			if got := diffSummary(diffs); got != test.want {
+				t.Errorf("findings are\n%s\nwant\n%s", got, test.want)
+			}
+			for _, diff := range diffs {
+				if diff.Kind != DiffRenamed {
+					continue
+				}
+				if diff.Message != test.message {
+					t.Errorf("renamed finding says %q, want %q", diff.Message, test.message)
+				}
+				if diff.Old == nil || diff.Old.Label != "Client" || diff.New == nil || diff.New.Label != "Conn" {
+					t.Errorf("renamed finding is of %v to %v, want of Client to Conn", diff.Old, diff.New)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,500 @@
+package exports
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var diffSections = []DiffKind{DiffAdded, DiffPromoted, DiffRemoved, DiffRenamed, DiffChanged, DiffMoved, DiffHygiene, DiffInconsistent, DiffPlatform}
+
+var diffSectionTitles = map[DiffKind]string{
+	DiffPromoted:     "exported (previously unexported)",
+	DiffInconsistent: "inconsistent across build variants",
+	DiffPlatform:     "platform dependent types",
+}
+
+// printDiffSections writes diffs grouped by the direction of the change,
+// so that additions are never confused with removals.
+func printDiffSections(w io.Writer, diffs []Diff) {
+	for _, kind := range diffSections {
+		section := make([]Diff, 0)
+		for _, diff := range diffs {
+			if diff.Kind == kind {
+				section = append(section, diff)
+			}
+		}
+		if len(section) == 0 {
+			continue
+		}
+		title := string(kind)
+		if t, ok := diffSectionTitles[kind]; ok {
+			title = t
+		}
+		fmt.Fprintf(w, "%s (%d):\n", title, len(section))
+		if kind == DiffChanged {
+			printDiffTree(w, section)
+			continue
+		}
+		for _, diff := range section {
+			text := diff.Message
+			if kind == DiffRenamed || kind == DiffMoved || kind == DiffHygiene || kind == DiffInconsistent || kind == DiffPlatform {
+				text = diff.String()
+			}
+			if positions := diffPositions(diff); positions != "" && kind != DiffRemoved {
+				text += " [" + positions + "]"
+			}
+			fmt.Fprintf(w, "\t%s\n", annotate(diff, text))
+		}
+	}
+}
+
+// annotate adds the rule that found diff, what the policy makes of it, who is to blame
+// for it and its fingerprint to its text.
+func annotate(diff Diff, text string) string {
+	if policy.frozen(diff) {
+		text = "[frozen] " + text
+	} else if diff.Severity != SeverityBreaking {
+		text = fmt.Sprintf("[%s] %s", diff.Severity, text)
+	} else if diff.Class() == ClassAdditive {
+		text = "[additive] " + text
+	}
+	if diff.Blame != "" {
+		text += " (" + diff.Blame + ")"
+	}
+	if len(diff.Metadata) > 0 {
+		text += " {" + formatMetadata(diff.Metadata) + "}"
+	}
+	return diff.Rule() + " " + text + " #" + diffFingerprint(diff)
+}
+
+// printDiffTree writes each changed symbol once, with the members and parameters
+// leading to its differences indented below it.
+func printDiffTree(w io.Writer, diffs []Diff) {
+	symbols := make([]string, 0)
+	bySymbol := make(map[string][]Diff)
+	for _, diff := range diffs {
+		if _, ok := bySymbol[diff.Symbol]; !ok {
+			symbols = append(symbols, diff.Symbol)
+		}
+		bySymbol[diff.Symbol] = append(bySymbol[diff.Symbol], diff)
+	}
+	for _, symbol := range symbols {
+		group := bySymbol[symbol]
+		header := symbol
+		if positions := diffPositions(group[0]); positions != "" {
+			header += " [" + positions + "]"
+		}
+		fmt.Fprintf(w, "\t%s\n", header)
+		var path []string
+		for _, diff := range group {
+			common := 0
+			for common < len(path) && common < len(diff.Path) && path[common] == diff.Path[common] {
+				common++
+			}
+			for i := common; i < len(diff.Path); i++ {
+				fmt.Fprintf(w, "%s%s\n", strings.Repeat("\t", i+2), diff.Path[i])
+			}
+			path = diff.Path
+			fmt.Fprintf(w, "%s%s\n", strings.Repeat("\t", len(diff.Path)+2), annotate(diff, diff.Message))
+		}
+	}
+}
+
+// Report is what a Renderer renders: the findings of a comparison.
+type Report struct {
+	Diffs []Diff
+}
+
+// Renderer renders a report in an output format. Programs embedding the checker
+// can add formats by registering a Renderer in Renderers.
+type Renderer interface {
+	Render(report Report) ([]byte, error)
+}
+
+// RendererFunc adapts a function to a Renderer.
+type RendererFunc func(report Report) ([]byte, error)
+
+func (f RendererFunc) Render(report Report) ([]byte, error) {
+	return f(report)
+}
+
+// writerRenderer adapts a function writing a report to a Renderer.
+func writerRenderer(write func(w io.Writer, diffs []Diff) error) Renderer {
+	return RendererFunc(func(report Report) ([]byte, error) {
+		buf := new(bytes.Buffer)
+		err := write(buf, report.Diffs)
+		return buf.Bytes(), err
+	})
+}
+
+// Renderers are the output formats selectable with -format, by name.
+var Renderers = map[string]Renderer{
+	"text": writerRenderer(func(w io.Writer, diffs []Diff) error {
+		printDiffSections(w, diffs)
+		return nil
+	}),
+	"codeclimate":  writerRenderer(writeCodeClimate),
+	"checkstyle":   writerRenderer(writeCheckstyle),
+	"summary-json": writerRenderer(writeSummary),
+	"json":         writerRenderer(writeJSONReport),
+	"sarif":        writerRenderer(writeSARIF),
+}
+
+// summary is a compact report for release dashboards and badges, counts only.
+type summary struct {
+	// Semver is the part of the version the changes call for: major, minor or patch
+	Semver   string         `json:"semver"`
+	Total    int            `json:"total"`
+	Breaking int            `json:"breaking"`
+	Warnings int            `json:"warnings"`
+	Info     int            `json:"info"`
+	Kinds    map[string]int `json:"kinds"`
+	// Classes counts findings by class, see Diff.Class
+	Classes map[Class]int `json:"classes"`
+}
+
+// semverBump recommends a major version for changes failing the policy, a minor one
+// for new API and a patch for anything else. Hygiene findings are about new API and
+// never call for a major version.
+func semverBump(diffs []Diff) string {
+	res := "patch"
+	for _, diff := range diffs {
+		switch {
+		case isNewExport(diff):
+			res = "minor"
+		case diff.Kind != DiffHygiene && policy.fails(diff):
+			return "major"
+		}
+	}
+	return res
+}
+
+func writeSummary(w io.Writer, diffs []Diff) error {
+	res := summary{Semver: semverBump(diffs), Total: len(diffs), Kinds: make(map[string]int), Classes: make(map[Class]int)}
+	for _, diff := range diffs {
+		switch {
+		case policy.fails(diff):
+			res.Breaking++
+		case diff.Severity == SeverityWarning:
+			res.Warnings++
+		default:
+			res.Info++
+		}
+		res.Kinds[string(diff.Kind)]++
+		res.Classes[diff.Class()]++
+	}
+	return json.NewEncoder(w).Encode(res)
+}
+
+// jsonFinding is a finding of the json report, with everything the text report shows
+// broken out into fields.
+type jsonFinding struct {
+	Rule     string   `json:"rule"`
+	Symbol   string   `json:"symbol"`
+	Kind     DiffKind `json:"kind"`
+	Category string   `json:"category,omitempty"`
+	Class    Class    `json:"class"`
+	Severity Severity `json:"severity"`
+	// Failing tells whether the finding fails compare under the policy in effect
+	Failing bool `json:"failing"`
+	Frozen  bool `json:"frozen,omitempty"`
+	// Path leads from the symbol to the difference, like param 0
+	Path      []string  `json:"path,omitempty"`
+	Message   string    `json:"message"`
+	Old       *jsonSide `json:"old,omitempty"`
+	New       *jsonSide `json:"new,omitempty"`
+	Reference string    `json:"reference,omitempty"`
+	Pointer   string    `json:"pointer,omitempty"`
+	Blame     string    `json:"blame,omitempty"`
+	// Metadata are the annotations of the symbol, like its owner, see -meta
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Fingerprint string            `json:"fingerprint"`
+}
+
+// jsonSide is a declaration on one side of a finding. Symbols extracted from source
+// have a line, those of a snapshot the offset recorded in it.
+type jsonSide struct {
+	File        string    `json:"file,omitempty"`
+	Line        int       `json:"line,omitempty"`
+	Offset      token.Pos `json:"offset,omitempty"`
+	Declaration *Symbol   `json:"declaration"`
+}
+
+func newJSONSide(sym *Symbol) *jsonSide {
+	if sym == nil {
+		return nil
+	}
+	res := &jsonSide{Declaration: sym}
+	if sym.FileName != "" {
+		res.File = relativePath(sym.FileName)
+		if res.Line = sym.Line; res.Line == 0 {
+			res.Offset = sym.Pos
+		}
+	}
+	return res
+}
+
+// jsonNode is a symbol, or a member or parameter leading to findings, in the tree of
+// the json report, which nests findings under what they are about like the text report.
+type jsonNode struct {
+	Name     string        `json:"name"`
+	Findings []jsonFinding `json:"findings,omitempty"`
+	Children []*jsonNode   `json:"children,omitempty"`
+}
+
+// child returns the child of n named name, adding it if there is none.
+func (n *jsonNode) child(name string) *jsonNode {
+	for _, child := range n.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	child := &jsonNode{Name: name}
+	n.Children = append(n.Children, child)
+	return child
+}
+
+// writeJSONReport writes every finding with its symbol, kind, both declarations and
+// positions, and how the policy rates it, for bots and CI systems to act on. The
+// findings are listed once flat, and once nested under their symbols and paths.
+func writeJSONReport(w io.Writer, diffs []Diff) error {
+	findings := make([]jsonFinding, 0, len(diffs))
+	root := new(jsonNode)
+	for _, diff := range diffs {
+		finding := jsonFinding{
+			Rule:        diff.Rule(),
+			Symbol:      diff.Symbol,
+			Kind:        diff.Kind,
+			Category:    diff.Category,
+			Class:       diff.Class(),
+			Severity:    diff.Severity,
+			Failing:     policy.fails(diff),
+			Frozen:      policy.frozen(diff),
+			Path:        diff.Path,
+			Message:     diff.Message,
+			Old:         newJSONSide(diff.Old),
+			New:         newJSONSide(diff.New),
+			Reference:   diff.Reference,
+			Pointer:     diff.Pointer,
+			Blame:       diff.Blame,
+			Metadata:    diff.Metadata,
+			Fingerprint: diffFingerprint(diff),
+		}
+		findings = append(findings, finding)
+		node := root.child(diff.Symbol)
+		for _, step := range diff.Path {
+			node = node.child(step)
+		}
+		node.Findings = append(node.Findings, finding)
+	}
+	if root.Children == nil {
+		root.Children = make([]*jsonNode, 0)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Findings []jsonFinding `json:"findings"`
+		Symbols  []*jsonNode   `json:"symbols"`
+	}{findings, root.Children})
+}
+
+// positionPattern matches the declaration positions symbols are printed with, see Symbol.String.
+var positionPattern = regexp.MustCompile(` \([^()]*:offset \d+\)`)
+
+// diffFingerprint identifies a finding by its content alone. Positions are left out,
+// so the fingerprint survives code moving around and findings being reordered.
+func diffFingerprint(diff Diff) string {
+	message := positionPattern.ReplaceAllString(diff.Message, "")
+	sum := sha256.Sum256([]byte(string(diff.Kind) + "\x00" + diff.Symbol + "\x00" + strings.Join(diff.Path, "\x00") + "\x00" + message))
+	return hex.EncodeToString(sum[:8])
+}
+
+// diffLocation is where a difference is best shown: the current declaration, or the
+// reference one for removed symbols. Paths are relative to the working directory when possible.
+func diffLocation(diff Diff) (string, int) {
+	sym := diff.New
+	if sym == nil {
+		sym = diff.Old
+	}
+	if sym == nil || sym.FileName == "" {
+		return "", 0
+	}
+	return relativePath(sym.FileName), sym.Line
+}
+
+// relativePath makes fileName relative to the working directory when it is below it.
+func relativePath(fileName string) string {
+	if abs, err := filepath.Abs(fileName); err == nil {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, abs); err == nil && !strings.HasPrefix(rel, "..") {
+				fileName = rel
+			}
+		}
+	}
+	return filepath.ToSlash(fileName)
+}
+
+// position is where sym is declared: file:line for symbols extracted from source,
+// or the file and offset recorded in a snapshot.
+func position(sym *Symbol) string {
+	if sym == nil || sym.FileName == "" {
+		return ""
+	}
+	if sym.Line > 0 {
+		return fmt.Sprintf("%s:%d", relativePath(sym.FileName), sym.Line)
+	}
+	// canonical snapshots leave positions out
+	if sym.Pos == 0 {
+		return sym.FileName
+	}
+	return fmt.Sprintf("%s:offset %d", sym.FileName, sym.Pos)
+}
+
+// diffPositions shows both sides of a difference, so either version can be opened.
+func diffPositions(diff Diff) string {
+	old, new := position(diff.Old), position(diff.New)
+	switch {
+	case old != "" && new != "":
+		return fmt.Sprintf("reference %s, current %s", old, new)
+	case old != "":
+		return "reference " + old
+	case new != "":
+		return "current " + new
+	}
+	return ""
+}
+
+type codeClimateIssue struct {
+	Type        string              `json:"type"`
+	CheckName   string              `json:"check_name"`
+	Description string              `json:"description"`
+	Categories  []string            `json:"categories"`
+	Fingerprint string              `json:"fingerprint"`
+	Severity    string              `json:"severity"`
+	Location    codeClimateLocation `json:"location"`
+	// BaselinePointer is the JSON Pointer of the snapshot entry the issue concerns
+	BaselinePointer string `json:"baseline_pointer,omitempty"`
+}
+
+type codeClimateLocation struct {
+	Path  string `json:"path"`
+	Lines struct {
+		Begin int `json:"begin"`
+	} `json:"lines"`
+}
+
+func codeClimateSeverity(diff Diff) string {
+	switch {
+	case policy.frozen(diff):
+		return "blocker"
+	case policy.fails(diff):
+		return "major"
+	case diff.Severity == SeverityWarning:
+		return "minor"
+	default:
+		return "info"
+	}
+}
+
+// writeCodeClimate writes a Code Climate issue list, the format of GitLab code quality reports.
+func writeCodeClimate(w io.Writer, diffs []Diff) error {
+	issues := make([]codeClimateIssue, 0, len(diffs))
+	for _, diff := range diffs {
+		issue := codeClimateIssue{
+			Type:            "issue",
+			CheckName:       "symbol-check/" + diff.Rule(),
+			Description:     diff.String(),
+			Categories:      []string{"Compatibility"},
+			Fingerprint:     diffFingerprint(diff),
+			Severity:        codeClimateSeverity(diff),
+			BaselinePointer: diff.Pointer,
+		}
+		issue.Location.Path, issue.Location.Lines.Begin = diffLocation(diff)
+		if issue.Location.Lines.Begin == 0 {
+			issue.Location.Lines.Begin = 1
+		}
+		issues = append(issues, issue)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(issues)
+}
+
+type checkstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+func checkstyleSeverity(diff Diff) string {
+	switch {
+	case policy.fails(diff):
+		return "error"
+	case diff.Severity == SeverityWarning:
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+// writeCheckstyle writes a checkstyle report, grouping differences by the file they are located in.
+// Differences without a location are attributed to the reference snapshot.
+func writeCheckstyle(w io.Writer, diffs []Diff) error {
+	report := checkstyleReport{Version: "4.3"}
+	files := make(map[string]int)
+	for _, diff := range diffs {
+		fileName, line := diffLocation(diff)
+		if fileName == "" {
+			fileName = diff.Reference
+		}
+		if line == 0 {
+			line = 1
+		}
+		i, ok := files[fileName]
+		if !ok {
+			i = len(report.Files)
+			files[fileName] = i
+			report.Files = append(report.Files, checkstyleFile{Name: fileName})
+		}
+		report.Files[i].Errors = append(report.Files[i].Errors, checkstyleError{
+			Line:     line,
+			Column:   1,
+			Severity: checkstyleSeverity(diff),
+			Message:  diff.String() + " #" + diffFingerprint(diff),
+			Source:   "symbol-check." + diff.Rule(),
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
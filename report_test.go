@@ -0,0 +1,122 @@
+package exports
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// flattenJSONTree writes the names of the nodes of a json report tree and the
+// messages of their findings, one per line, indented by depth.
+func flattenJSONTree(buf *strings.Builder, nodes []*jsonNode, depth int) {
+	for _, node := range nodes {
+		buf.WriteString(strings.Repeat("\t", depth) + node.Name + "\n")
+		for _, finding := range node.Findings {
+			buf.WriteString(strings.Repeat("\t", depth+1) + "- " + finding.Message + "\n")
+		}
+		flattenJSONTree(buf, node.Children, depth+1)
+	}
+}
+
+func TestJSONReportTree(t *testing.T) {
+	setPolicy(t, nil)
+	changed := strings.NewReplacer(
+		"func New(name string, size int) (Plugin, error) {", "func New(name string, size int64) (Plugin, error) {",
+		"X, Y    int", "X, Y    float64",
+		"Enable() error", "Enable(force bool) error",
+	).Replace(formattingSource)
+	dir := t.TempDir()
+	reference := extractSource(t, dir, formattingSource)
+	diffs := compare(reference, extractSource(t, dir, changed))
+
+	buf := new(bytes.Buffer)
+	if err := writeJSONReport(buf, diffs); err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Findings []jsonFinding `json:"findings"`
+		Symbols  []*jsonNode   `json:"symbols"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Findings) != len(diffs) {
+		t.Errorf("%d flat findings, want %d", len(report.Findings), len(diffs))
+	}
+	tree := new(strings.Builder)
+	flattenJSONTree(tree, report.Symbols, 0)
+	want := `.Config
+	.X
+		- field type changed from int to float64
+	.Y
+		- field type changed from int to float64
+.New
+	param 1
+		- type changed from int to int64
+.Plugin
+	.Enable
+		params
+			- number of parameters changed from 0 to 1
+`
+	if tree.String() != want {
+		t.Errorf("tree is\n%s\nwant\n%s", tree, want)
+	}
+}
+
+// findingsSource is formattingSource with a symbol added, one removed and two changed.
+var findingsSource = strings.NewReplacer(
+	"func New(name string, size int) (Plugin, error) {", "func New(name string, size int64) (Plugin, error) {",
+	"Enable() error", "Enable(force bool) error",
+	"func (c *Config) Validate() error {\n\treturn nil\n}\n", "",
+).Replace(formattingSource) + "\nfunc Register(p Plugin) {}\n"
+
+// fingerprintLines lists the fingerprint, kind, symbol and path of every finding, sorted
+// by symbol, as the golden file of TestDiffFingerprintGolden does.
+func fingerprintLines(diffs []Diff) string {
+	lines := make([]string, len(diffs))
+	for i, diff := range diffs {
+		lines[i] = strings.TrimSpace(fmt.Sprintf("%s %s %s %s", diffFingerprint(diff), diff.Kind, diff.Symbol, strings.Join(diff.Path, "/")))
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i][17:] < lines[j][17:] })
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestDiffFingerprintGolden(t *testing.T) {
+	setPolicy(t, nil)
+	changed := findingsSource
+	// moved has every declaration of a source at another offset and line
+	moved := func(source string) string {
+		return strings.Replace(source, "import \"io\"\n", "import \"io\"\n\n// moved is declared before the rest since.\nvar moved = 0\n", 1)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "fingerprints.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := string(data)
+	tests := []struct {
+		name               string
+		reference, current string
+	}{
+		{name: "as written", reference: formattingSource, current: changed},
+		{name: "current moved", reference: formattingSource, current: moved(changed)},
+		{name: "both moved", reference: moved(formattingSource), current: moved(changed)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reference := extractSource(t, t.TempDir(), test.reference)
+			current := extractSource(t, t.TempDir(), test.current)
+			// fingerprints are the same for every run, not only within one
+			for run := 0; run < 3; run++ {
+				if got := fingerprintLines(compare(reference, current)); got != want {
+					t.Fatalf("fingerprints of run %d are\n%s\nwant\n%s", run, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package exports
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"os"
+	"strings"
+)
+
+// reverse turns compare around: instead of what the package broke since the reference,
+// it lists what the package lacks to provide everything the reference does, like a
+// plugin upgrading to a newer host API.
+var reverse bool
+
+// signature renders the parameter and result types of a func.
+func signature(spec *FuncSpec) string {
+	if spec == nil {
+		return "()"
+	}
+	types := func(list SymbolList) []string {
+		res := make([]string, len(list))
+		for i := range list {
+			res[i] = typeExpr(list[i])
+		}
+		return res
+	}
+	res := "(" + strings.Join(types(spec.Params), ", ") + ")"
+	switch returns := types(spec.Returns); len(returns) {
+	case 0:
+	case 1:
+		res += " " + returns[0]
+	default:
+		res += " (" + strings.Join(returns, ", ") + ")"
+	}
+	return res
+}
+
+// declaration renders a symbol, or a member of one, as it would be declared.
+func declaration(sym Symbol) string {
+	switch sym.SymbolType {
+	case "func":
+		return "func " + sym.Label + stubTypeParams(sym.TypeParams) + signature(sym.FuncSpec)
+	case "method":
+		receiver := sym.ReceiverType
+		if sym.PointerReceiver {
+			receiver = "*" + receiver
+		}
+		if sym.ReceiverType == "" {
+			// a method of an interface
+			return sym.Label + signature(sym.FuncSpec)
+		}
+		return fmt.Sprintf("func (%s) %s%s", receiver, sym.Label, signature(sym.FuncSpec))
+	case "var", "const":
+		res := sym.SymbolType + " " + sym.Label
+		if sym.ValueType != nil {
+			res += " " + typeExpr(*sym.ValueType)
+		}
+		if sym.Value != "" {
+			res += " = " + sym.Value
+		}
+		return res
+	case "member":
+		if sym.UnderlyingType != "" {
+			return sym.Label + " " + sym.UnderlyingType
+		}
+		return sym.Label
+	case "embed":
+		return sym.Label
+	case "alias":
+		return "type " + sym.Label + stubTypeParams(sym.TypeParams) + " = " + typeExpr(sym)
+	case "struct", "interface":
+		if len(sym.Members) == 0 {
+			return "type " + sym.Label + stubTypeParams(sym.TypeParams) + " " + sym.SymbolType + "{}"
+		}
+		fallthrough
+	default:
+		return "type " + sym.Label + stubTypeParams(sym.TypeParams) + " " + typeExpr(sym)
+	}
+}
+
+// gap is a symbol of the reference the package lacks, or lacks members of.
+type gap struct {
+	Declarations []string `json:"declarations"`
+	Optional     bool     `json:"optional,omitempty"`
+}
+
+// conformance is how much of the API of a reference a package provides. Symbols marked
+// optional with -optional are capabilities a package may provide, the rest is required.
+type conformance struct {
+	Reference   string `json:"reference"`
+	Required    int    `json:"required"`
+	RequiredMet int    `json:"requiredMet"`
+	Optional    int    `json:"optional"`
+	OptionalMet int    `json:"optionalMet"`
+	// Score is the percentage of required symbols provided
+	Score int   `json:"score"`
+	Gaps  []gap `json:"gaps"`
+}
+
+// complete reports whether every required symbol is provided.
+func (c conformance) complete() bool {
+	return c.RequiredMet == c.Required
+}
+
+func (c conformance) String() string {
+	res := fmt.Sprintf("%s: implements %d/%d required symbols (%d%%)", c.Reference, c.RequiredMet, c.Required, c.Score)
+	if c.Optional > 0 {
+		res += fmt.Sprintf(", %d/%d optional capabilities", c.OptionalMet, c.Optional)
+	}
+	return res
+}
+
+// missingSymbols rates how much of the exported symbols of reference current provides.
+// A symbol is missing with the declaration of it, or of the fields and methods of its
+// structs and interfaces current declares without them.
+func missingSymbols(reference, current SymbolList) conformance {
+	declared := make(map[string]*Symbol)
+	for i := range current {
+		if !current[i].Unexported {
+			declared[current[i].Ident()] = &current[i]
+		}
+	}
+	res := conformance{Gaps: make([]gap, 0)}
+	for _, sym := range reference {
+		if sym.Unexported {
+			continue
+		}
+		missing := gap{Optional: sym.Optional}
+		if have := declared[sym.Ident()]; have == nil {
+			missing.Declarations = append(missing.Declarations, declaration(sym))
+		} else {
+			members := make(map[string]bool)
+			for _, member := range have.Members {
+				members[member.Label] = true
+			}
+			for _, member := range sym.Members {
+				if !members[member.Label] && ast.IsExported(member.Label) {
+					missing.Declarations = append(missing.Declarations, fmt.Sprintf("%s: %s", sym.Label, declaration(member)))
+				}
+			}
+		}
+		met := len(missing.Declarations) == 0
+		if !met {
+			res.Gaps = append(res.Gaps, missing)
+		}
+		switch {
+		case sym.Optional && met:
+			res.Optional++
+			res.OptionalMet++
+		case sym.Optional:
+			res.Optional++
+		case met:
+			res.Required++
+			res.RequiredMet++
+		default:
+			res.Required++
+		}
+	}
+	res.Score = 100
+	if res.Required > 0 {
+		res.Score = res.RequiredMet * 100 / res.Required
+	}
+	return res
+}
+
+// runReverse lists what the package in dir lacks to provide the API of every reference,
+// with a score of how much it provides, and exits with 2 if it lacks required symbols.
+// With -format json, the scores and gaps are written as JSON instead.
+func runReverse(dir, pkgName string, references []string) {
+	current, err := extract(dir, pkgName)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	complete := true
+	results := make([]conformance, 0, len(references))
+	for _, reference := range references {
+		var refData *Baseline
+		if baselineStore != nil {
+			refData, err = loadStoredReference(baselineStore, reference)
+		} else {
+			refData, err = loadReference(reference)
+		}
+		if err != nil {
+			exitWithStatusError(err, 1)
+		}
+		res := missingSymbols(refData.Symbols, current)
+		res.Reference = reference
+		results = append(results, res)
+		complete = complete && res.complete()
+		if outputFormat == "json" {
+			continue
+		}
+		fmt.Fprintln(os.Stdout, res)
+		if len(res.Gaps) == 0 {
+			continue
+		}
+		count := 0
+		for _, missing := range res.Gaps {
+			count += len(missing.Declarations)
+		}
+		fmt.Fprintf(os.Stdout, "to provide the API of %s, add (%d):\n", reference, count)
+		for _, missing := range res.Gaps {
+			for _, decl := range missing.Declarations {
+				if missing.Optional {
+					decl += " (optional)"
+				}
+				fmt.Fprintf(os.Stdout, "\t%s\n", strings.ReplaceAll(decl, "\n", "\n\t"))
+			}
+		}
+	}
+	if outputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			exitWithStatusError(err, 1)
+		}
+	}
+	if !complete {
+		exitWithStatusString("required symbols are missing", 2)
+	}
+	exitWithStatusString("nothing required is missing", 0)
+}
@@ -0,0 +1,190 @@
+package exports
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// rewriteRule is a gofmt -r rule migrating consumers from the reference API to the current one.
+type rewriteRule struct {
+	Reason string
+	Rule   string
+}
+
+// rewriteWildcards are the gofmt -r wildcards used for call arguments. p stands
+// for the package and x for method receivers, so neither is used here.
+const rewriteWildcards = "abcdefghijklmnoqrstuvwyz"
+
+func sameSymbol(a, b Symbol) bool {
+	return flagComparer().sameSymbol(a, b)
+}
+
+// findRenames pairs removed symbols with added symbols that are identical except for their label.
+// Only unambiguous pairs are returned, keyed by the removed symbol.
+func findRenames(diffs []Diff) map[*Symbol]*Symbol {
+	candidates := make(map[*Symbol][]*Symbol)
+	claims := make(map[*Symbol]int)
+	for _, removed := range diffs {
+		if removed.Kind != DiffRemoved {
+			continue
+		}
+		for _, added := range diffs {
+			if added.Kind != DiffAdded || added.New.SymbolType != removed.Old.SymbolType || added.New.ReceiverType != removed.Old.ReceiverType {
+				continue
+			}
+			renamed := *added.New
+			renamed.Label = removed.Old.Label
+			if sameSymbol(*removed.Old, renamed) {
+				candidates[removed.Old] = append(candidates[removed.Old], added.New)
+				claims[added.New]++
+			}
+		}
+	}
+	res := make(map[*Symbol]*Symbol)
+	for old, news := range candidates {
+		if len(news) == 1 && claims[news[0]] == 1 {
+			res[old] = news[0]
+		}
+	}
+	return res
+}
+
+// selectorPattern is how consumers refer to a symbol in a gofmt -r pattern.
+func selectorPattern(sym *Symbol) string {
+	if sym.ReceiverType != "" {
+		return "x." + sym.Label
+	}
+	return "p." + sym.Label
+}
+
+func callPattern(sym *Symbol, args []string) string {
+	return fmt.Sprintf("%s(%s)", selectorPattern(sym), strings.Join(args, ", "))
+}
+
+// signatureRewrite suggests a rule for signature changes consumers can migrate
+// to mechanically: reordered parameters of distinct types and dropped trailing parameters.
+func signatureRewrite(old, new *Symbol) *rewriteRule {
+	if old.FuncSpec == nil || new.FuncSpec == nil || len(old.FuncSpec.Params) > len(rewriteWildcards) {
+		return nil
+	}
+	oldParams, newParams := old.FuncSpec.Params, new.FuncSpec.Params
+	if !sameSymbolList(old.FuncSpec.Returns, new.FuncSpec.Returns) {
+		return nil
+	}
+	args := make([]string, len(oldParams))
+	for i := range oldParams {
+		args[i] = string(rewriteWildcards[i])
+	}
+
+	if len(newParams) < len(oldParams) && sameSymbolList(oldParams[:len(newParams)], newParams) {
+		return &rewriteRule{
+			Reason: fmt.Sprintf("%s dropped its trailing parameters", new.Ident()),
+			Rule:   callPattern(old, args) + " -> " + callPattern(new, args[:len(newParams)]),
+		}
+	}
+
+	if len(newParams) != len(oldParams) {
+		return nil
+	}
+	newArgs := make([]string, len(newParams))
+	for j, param := range newParams {
+		match := -1
+		for i, oldParam := range oldParams {
+			if sameSymbol(oldParam, param) {
+				if match >= 0 {
+					// parameters of the same type cannot be told apart
+					return nil
+				}
+				match = i
+			}
+		}
+		if match < 0 {
+			return nil
+		}
+		newArgs[j] = args[match]
+	}
+	if strings.Join(newArgs, "") == strings.Join(args, "") {
+		return nil
+	}
+	return &rewriteRule{
+		Reason: fmt.Sprintf("%s reordered its parameters", new.Ident()),
+		Rule:   callPattern(old, args) + " -> " + callPattern(new, newArgs),
+	}
+}
+
+func sameSymbolList(a, b SymbolList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameSymbol(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// suggestRewrites derives gofmt -r rules from renames and mechanical signature changes in diffs.
+func suggestRewrites(diffs []Diff) []rewriteRule {
+	rules := make([]rewriteRule, 0)
+	renames := findRenames(diffs)
+	for _, removed := range diffs {
+		if removed.Kind != DiffRemoved {
+			continue
+		}
+		new, ok := renames[removed.Old]
+		if !ok {
+			continue
+		}
+		reason := fmt.Sprintf("%s was renamed to %s", removed.Old.Ident(), new.Ident())
+		if new.ReceiverType != "" {
+			reason += fmt.Sprintf(", the rule matches every selector named %s so review it before applying", removed.Old.Label)
+		}
+		rules = append(rules, rewriteRule{Reason: reason, Rule: selectorPattern(removed.Old) + " -> " + selectorPattern(new)})
+	}
+
+	for _, renamed := range diffs {
+		if renamed.Kind == DiffRenamed && renamed.Old != nil && renamed.New != nil {
+			rules = append(rules, rewriteRule{
+				Reason: fmt.Sprintf("%s was renamed to %s", renamed.Old.Ident(), renamed.New.Ident()),
+				Rule:   selectorPattern(renamed.Old) + " -> " + selectorPattern(renamed.New),
+			})
+		}
+	}
+
+	seen := make(map[*Symbol]bool)
+	for _, changed := range diffs {
+		if changed.Kind != DiffChanged || seen[changed.New] {
+			continue
+		}
+		seen[changed.New] = true
+		if rule := signatureRewrite(changed.Old, changed.New); rule != nil {
+			rules = append(rules, *rule)
+		}
+	}
+	return rules
+}
+
+// writeRewrites writes rules as a shell script applying them to the working directory, or to stdout for "-".
+func writeRewrites(fileName string, rules []rewriteRule) error {
+	buf := new(bytes.Buffer)
+	fmt.Fprintln(buf, "#!/bin/sh")
+	fmt.Fprintln(buf, "# generated by symbol-check, rewrites consumer code to the current API")
+	seen := make(map[rewriteRule]bool)
+	for _, rule := range rules {
+		// references compared together often suggest the same rules
+		if seen[rule] {
+			continue
+		}
+		seen[rule] = true
+		fmt.Fprintf(buf, "\n# %s\ngofmt -w -r '%s' .\n", rule.Reason, rule.Rule)
+	}
+	if fileName == "-" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return ioutil.WriteFile(fileName, buf.Bytes(), 0755)
+}
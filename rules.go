@@ -0,0 +1,100 @@
+package exports
+
+import "regexp"
+
+// ruleIDs are the stable identifiers of the checks, by category of their findings. An ID
+// is never renumbered or reused, new checks take the next free one.
+var ruleIDs = map[string]string{
+	string(DiffRemoved):      "SC001",
+	string(DiffAdded):        "SC002",
+	string(DiffPromoted):     "SC003",
+	string(DiffRenamed):      "SC004",
+	"kind":                   "SC005",
+	"type":                   "SC006",
+	"alias":                  "SC007",
+	"receiver":               "SC008",
+	"signature":              "SC009",
+	"interface-added":        "SC010",
+	"interface-removed":      "SC011",
+	"member-added":           "SC012",
+	"member-removed":         "SC013",
+	"embedding":              "SC014",
+	"promotion":              "SC015",
+	"typeParams":             "SC016",
+	"widened":                "SC017",
+	"method-added":           "SC018",
+	"deprecated":             "SC019",
+	"undeprecated":           "SC020",
+	string(DiffMoved):        "SC021",
+	string(DiffInconsistent): "SC022",
+	string(DiffHygiene):      "SC023",
+	"tag":                    "SC024",
+	"package":                "SC025",
+	"constraint":             "SC026",
+	string(DiffPlatform):     "SC027",
+	"zero-value":             "SC028",
+	"value":                  "SC029",
+}
+
+// rulePattern matches rule IDs, which suppression files may list in place of fingerprints.
+var rulePattern = regexp.MustCompile(`^SC\d{3}$`)
+
+// Rule is the ID of the check that found d, like SC010 for a method added to an interface.
+// Categories without an ID of their own, like members promoted from unexported ones,
+// fall back to the ID of their kind.
+func (d Diff) Rule() string {
+	if id, ok := ruleIDs[d.category()]; ok {
+		return id
+	}
+	return ruleIDs[string(d.Kind)]
+}
+
+func knownRule(id string) bool {
+	for _, known := range ruleIDs {
+		if id == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleOf returns the ID of a rule given by ID or by the category of its findings, like
+// removed or signature, or "" for neither.
+func ruleOf(name string) string {
+	if knownRule(name) {
+		return name
+	}
+	return ruleIDs[name]
+}
+
+// exitsOn reports whether d is found by one of the rules -exit-on lists.
+func (p Policy) exitsOn(d Diff) bool {
+	for _, name := range p.ExitOn {
+		if ruleOf(name) == d.Rule() {
+			return true
+		}
+	}
+	return false
+}
+
+// enabled reports whether the rule that found d is switched on, see Policy.Disable.
+func (p Policy) enabled(d Diff) bool {
+	if len(p.EnableOnly) > 0 && !p.EnableOnly.contains(d.Rule()) {
+		return false
+	}
+	return !p.Disable.contains(d.Rule())
+}
+
+// enabledDiffs leaves out the findings of rules that are switched off, except changes
+// of frozen symbols, which only -unfreeze acknowledges.
+func (p Policy) enabledDiffs(diffs []Diff) []Diff {
+	res := make([]Diff, 0, len(diffs))
+	for _, diff := range diffs {
+		if p.enabled(diff) || p.frozen(diff) {
+			res = append(res, diff)
+		} else {
+			tracef("policy: %s %s left out, rule %s is disabled", diff.Symbol, diff.category(), diff.Rule())
+		}
+	}
+	return res
+}
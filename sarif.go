@@ -0,0 +1,127 @@
+package exports
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// sarifLog is a SARIF 2.1.0 log, the format of GitHub code scanning and Azure DevOps.
+// Only the properties the checker fills in are declared.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool struct {
+		Driver sarifDriver `json:"driver"`
+	} `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifText    `json:"shortDescription"`
+	FullDescription  *sarifText   `json:"fullDescription,omitempty"`
+	Help             *sarifText   `json:"help,omitempty"`
+	Properties       sarifRuleTag `json:"properties"`
+}
+
+type sarifRuleTag struct {
+	Tags []string `json:"tags"`
+}
+
+type sarifText struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifText         `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	Properties          map[string]string `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region struct {
+			StartLine int `json:"startLine"`
+		} `json:"region"`
+	} `json:"physicalLocation"`
+}
+
+func sarifLevel(diff Diff) string {
+	switch {
+	case policy.fails(diff):
+		return "error"
+	case diff.Severity == SeverityWarning:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// writeSARIF writes a SARIF log with a rule for every rule ID found and a result for
+// every finding, located like code quality issues. Findings without a location in
+// source, like removals from files that are gone, are located in the reference.
+func writeSARIF(w io.Writer, diffs []Diff) error {
+	run := sarifRun{Results: make([]sarifResult, 0, len(diffs))}
+	run.Tool.Driver = sarifDriver{Name: "symbol-check", InformationURI: "https://github.com/eternal-flame-AD/go-exports", Rules: make([]sarifRule, 0)}
+	rules := make(map[string]int)
+	for _, diff := range diffs {
+		id := diff.Rule()
+		index, ok := rules[id]
+		if !ok {
+			index = len(run.Tool.Driver.Rules)
+			rules[id] = index
+			rule := sarifRule{ID: id, Name: diff.category(), ShortDescription: sarifText{diff.category()}, Properties: sarifRuleTag{Tags: []string{"compatibility"}}}
+			if e, ok := explanations[diff.category()]; ok {
+				rule.FullDescription, rule.Help = &sarifText{e.Why}, &sarifText{e.Remedy}
+			}
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+		}
+		result := sarifResult{
+			RuleID:              id,
+			RuleIndex:           index,
+			Level:               sarifLevel(diff),
+			Message:             sarifText{diff.String()},
+			PartialFingerprints: map[string]string{"symbolCheck/v1": diffFingerprint(diff)},
+		}
+		if diff.Pointer != "" {
+			result.Properties = map[string]string{"baselinePointer": diff.Pointer}
+		}
+		var location sarifLocation
+		fileName, line := diffLocation(diff)
+		if fileName == "" {
+			fileName = relativePath(diff.Reference)
+		}
+		if line == 0 {
+			line = 1
+		}
+		location.PhysicalLocation.ArtifactLocation.URI = fileName
+		location.PhysicalLocation.Region.StartLine = line
+		result.Locations = []sarifLocation{location}
+		run.Results = append(run.Results, result)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
@@ -0,0 +1,141 @@
+package exports
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Signatures of a reference are kept next to it in <reference>.sig, one
+// "<public key> <signature>" line per approval, both base64 encoded.
+const signatureSuffix = ".sig"
+
+func readKey(fileName string, size int) ([]byte, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	if len(key) != size {
+		return nil, fmt.Errorf("%s: not an ed25519 key", fileName)
+	}
+	return key, nil
+}
+
+// readTrustedKeys reads a file of base64 encoded public keys, one per line, ignoring # comments.
+func readTrustedKeys(fileName string) ([]ed25519.PublicKey, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	keys := make([]ed25519.PublicKey, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.Fields(line)[0])
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%s: invalid public key %q", fileName, line)
+		}
+		keys = append(keys, ed25519.PublicKey(key))
+	}
+	return keys, scanner.Err()
+}
+
+// verifyReference fails unless the reference carries a valid signature from one of the trusted keys.
+func verifyReference(fileName, trustedKeysFile string) error {
+	trusted, err := readTrustedKeys(trustedKeysFile)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	sigs, err := ioutil.ReadFile(fileName + signatureSuffix)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s is not signed, approve it with the sign subcommand", fileName)
+	} else if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(sigs), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		pub, err1 := base64.StdEncoding.DecodeString(fields[0])
+		sig, err2 := base64.StdEncoding.DecodeString(fields[1])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		for _, key := range trusted {
+			if bytes.Equal(key, pub) && ed25519.Verify(key, data, sig) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s is not signed by a trusted key, it may have been changed after approval", fileName)
+}
+
+func runKeygen(args []string) {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	out := flags.String("o", "symbol-check", "key pair to write, as <o>.key and <o>.pub")
+	flags.Parse(args)
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	if err := ioutil.WriteFile(*out+".key", []byte(base64.StdEncoding.EncodeToString(priv.Seed())+"\n"), 0600); err != nil {
+		exitWithStatusError(err, 1)
+	}
+	if err := ioutil.WriteFile(*out+".pub", []byte(base64.StdEncoding.EncodeToString(pub)+"\n"), 0644); err != nil {
+		exitWithStatusError(err, 1)
+	}
+}
+
+// runSign approves a reference by appending a signature to its .sig file.
+func runSign(args []string) {
+	flags := flag.NewFlagSet("sign", flag.ExitOnError)
+	reference := flags.String("c", "", "reference snapshot to approve")
+	keyFile := flags.String("key", "", "private key written by keygen")
+	flags.Parse(args)
+	if *reference == "" || *keyFile == "" {
+		exitWithStatusString("sign: -c and -key are required", 1)
+	}
+
+	seed, err := readKey(*keyFile, ed25519.SeedSize)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	data, err := ioutil.ReadFile(*reference)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	priv := ed25519.NewKeyFromSeed(seed)
+	line := fmt.Sprintf("%s %s\n",
+		base64.StdEncoding.EncodeToString(priv.Public().(ed25519.PublicKey)),
+		base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data)))
+
+	sigFile, err := os.OpenFile(*reference+signatureSuffix, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	defer sigFile.Close()
+	if _, err := sigFile.WriteString(line); err != nil {
+		exitWithStatusError(err, 1)
+	}
+}
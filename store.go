@@ -0,0 +1,374 @@
+package exports
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BaselineStore keeps snapshots by name and version, so they can live in an
+// artifact store instead of next to the code.
+type BaselineStore interface {
+	Load(name, version string) (*Baseline, error)
+	Save(name, version string, baseline *Baseline) error
+	// List returns the versions stored for name, oldest first.
+	List(name string) ([]string, error)
+}
+
+// baselineKey is where a version of a snapshot is kept, relative to the root of a store.
+func baselineKey(name, version string) string {
+	return path.Join(name, version+".json")
+}
+
+// versionLess orders versions like v1.9.0 before v1.10.0 by comparing runs of digits numerically.
+func versionLess(a, b string) bool {
+	for a != "" && b != "" {
+		i, j := digitsPrefix(a), digitsPrefix(b)
+		if i > 0 && j > 0 {
+			na, nb := strings.TrimLeft(a[:i], "0"), strings.TrimLeft(b[:j], "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[i:], b[j:]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func digitsPrefix(s string) int {
+	i := 0
+	for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+func sortVersions(versions []string) {
+	sort.Slice(versions, func(i, j int) bool { return versionLess(versions[i], versions[j]) })
+}
+
+// versionsFromKeys picks the versions of name out of keys relative to the root of a store.
+func versionsFromKeys(name string, keys []string) []string {
+	res := make([]string, 0)
+	for _, key := range keys {
+		if path.Dir(key) == name && strings.HasSuffix(key, ".json") {
+			res = append(res, strings.TrimSuffix(path.Base(key), ".json"))
+		}
+	}
+	sortVersions(res)
+	return res
+}
+
+// FileBaselineStore keeps snapshots as <Root>/<name>/<version>.json.
+type FileBaselineStore struct {
+	Root string
+}
+
+func (s *FileBaselineStore) Load(name, version string) (*Baseline, error) {
+	return loadReference(filepath.Join(s.Root, filepath.FromSlash(baselineKey(name, version))))
+}
+
+func (s *FileBaselineStore) Save(name, version string, baseline *Baseline) error {
+	data, err := json.Marshal(baseline)
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(s.Root, filepath.FromSlash(baselineKey(name, version))), data)
+}
+
+func (s *FileBaselineStore) List(name string) ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(s.Root, filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		keys = append(keys, path.Join(name, entry.Name()))
+	}
+	return versionsFromKeys(name, keys), nil
+}
+
+// HTTPBaselineStore keeps snapshots on a web server at <BaseURL>/<name>/<version>.json.
+// Snapshots are saved with PUT, and GET <BaseURL>/<name>/ must return a JSON array of versions.
+type HTTPBaselineStore struct {
+	BaseURL string
+	// Header is sent with every request, for example for authorization.
+	Header http.Header
+	Client *http.Client
+}
+
+func (s *HTTPBaselineStore) do(method, key string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, strings.TrimSuffix(s.BaseURL, "/")+"/"+key, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range s.Header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return doRequest(s.Client, req)
+}
+
+func (s *HTTPBaselineStore) Load(name, version string) (*Baseline, error) {
+	data, err := s.do(http.MethodGet, baselineKey(name, version), nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseBaseline(name+"@"+version, data)
+}
+
+func (s *HTTPBaselineStore) Save(name, version string, baseline *Baseline) error {
+	data, err := json.Marshal(baseline)
+	if err != nil {
+		return err
+	}
+	_, err = s.do(http.MethodPut, baselineKey(name, version), data)
+	return err
+}
+
+func (s *HTTPBaselineStore) List(name string) ([]string, error) {
+	data, err := s.do(http.MethodGet, name+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0)
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("cannot parse versions of %s: %v", name, err)
+	}
+	sortVersions(res)
+	return res, nil
+}
+
+// S3BaselineStore keeps snapshots in an S3 compatible bucket at <Prefix>/<name>/<version>.json.
+// Requests are made path style and signed with AWS signature version 4.
+type S3BaselineStore struct {
+	// Endpoint is the URL of the service, like https://s3.eu-west-1.amazonaws.com.
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+}
+
+// s3Escape escapes like AWS expects in canonical requests: everything but unreserved characters.
+func s3Escape(s string, keepSlash bool) string {
+	buf := new(strings.Builder)
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			buf.WriteByte(b)
+		case b == '/' && keepSlash:
+			buf.WriteByte(b)
+		default:
+			fmt.Fprintf(buf, "%%%02X", b)
+		}
+	}
+	return buf.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func (s *S3BaselineStore) do(method, key string, query url.Values, body []byte) ([]byte, error) {
+	escapedPath := "/" + s3Escape(s.Bucket, false)
+	if key != "" {
+		escapedPath += "/" + s3Escape(key, true)
+	}
+	queryKeys := make([]string, 0, len(query))
+	for name := range query {
+		queryKeys = append(queryKeys, name)
+	}
+	sort.Strings(queryKeys)
+	queryParts := make([]string, 0, len(query))
+	for _, name := range queryKeys {
+		queryParts = append(queryParts, s3Escape(name, false)+"="+s3Escape(query.Get(name), false))
+	}
+	canonicalQuery := strings.Join(queryParts, "&")
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(s.Endpoint, "/")+escapedPath+"?"+canonicalQuery, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, escapedPath, canonicalQuery, body, time.Now())
+	return doRequest(s.Client, req)
+}
+
+// sign signs req, made at now, with AWS signature version 4. escapedPath and
+// canonicalQuery are the path and query of req as they appear in the canonical request.
+func (s *S3BaselineStore) sign(req *http.Request, escapedPath, canonicalQuery string, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate, date := now.Format("20060102T150405Z"), now.Format("20060102")
+	payloadSum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payloadSum[:])
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapedPath,
+		canonicalQuery,
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		"host;x-amz-content-sha256;x-amz-date",
+		payloadHash,
+	}, "\n")
+	requestSum := sha256.Sum256([]byte(canonicalRequest))
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestSum[:])
+	signingKey := hmacSHA256(hmacSHA256(hmacSHA256(hmacSHA256([]byte("AWS4"+s.SecretKey), date), s.Region), "s3"), "aws4_request")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=%s",
+		s.AccessKey, scope, hex.EncodeToString(hmacSHA256(signingKey, stringToSign))))
+}
+
+func (s *S3BaselineStore) key(name, version string) string {
+	return path.Join(s.Prefix, baselineKey(name, version))
+}
+
+func (s *S3BaselineStore) Load(name, version string) (*Baseline, error) {
+	data, err := s.do(http.MethodGet, s.key(name, version), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseBaseline(name+"@"+version, data)
+}
+
+func (s *S3BaselineStore) Save(name, version string, baseline *Baseline) error {
+	data, err := json.Marshal(baseline)
+	if err != nil {
+		return err
+	}
+	_, err = s.do(http.MethodPut, s.key(name, version), nil, data)
+	return err
+}
+
+func (s *S3BaselineStore) List(name string) ([]string, error) {
+	prefix := path.Join(s.Prefix, name) + "/"
+	// keys are listed with the prefix, which versionsFromKeys does not expect
+	base := ""
+	if s.Prefix != "" {
+		base = path.Clean(s.Prefix) + "/"
+	}
+	keys := make([]string, 0)
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		data, err := s.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		if err := xml.Unmarshal(data, &page); err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, strings.TrimPrefix(object.Key, base))
+		}
+		if !page.IsTruncated {
+			break
+		}
+		query.Set("continuation-token", page.NextContinuationToken)
+	}
+	return versionsFromKeys(name, keys), nil
+}
+
+func doRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s %s: %s", req.Method, req.URL.Redacted(), resp.Status)
+	}
+	return data, nil
+}
+
+// openBaselineStore opens a store from a spec: a directory, an http(s) URL, or
+// s3://bucket/prefix?endpoint=...&region=... with credentials taken from
+// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+func openBaselineStore(spec string) (BaselineStore, error) {
+	switch {
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return &HTTPBaselineStore{BaseURL: spec}, nil
+	case strings.HasPrefix(spec, "s3://"):
+		u, err := url.Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		store := &S3BaselineStore{
+			Endpoint:  u.Query().Get("endpoint"),
+			Region:    u.Query().Get("region"),
+			Bucket:    u.Host,
+			Prefix:    strings.Trim(u.Path, "/"),
+			AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		}
+		if store.Region == "" {
+			store.Region = "us-east-1"
+		}
+		if store.Endpoint == "" {
+			store.Endpoint = "https://s3." + store.Region + ".amazonaws.com"
+		}
+		return store, nil
+	default:
+		return &FileBaselineStore{Root: strings.TrimPrefix(spec, "file:")}, nil
+	}
+}
+
+// splitBaselineRef splits name@version, where a missing version is the latest stored one.
+func splitBaselineRef(store BaselineStore, ref string) (string, string, error) {
+	i := strings.LastIndex(ref, "@")
+	if i >= 0 {
+		return ref[:i], ref[i+1:], nil
+	}
+	versions, err := store.List(ref)
+	if err != nil {
+		return "", "", err
+	}
+	if len(versions) == 0 {
+		return "", "", fmt.Errorf("no snapshots of %s stored", ref)
+	}
+	return ref, versions[len(versions)-1], nil
+}
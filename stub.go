@@ -0,0 +1,299 @@
+package exports
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// typeExpr renders the Go type expression recorded in a symbol.
+// Types the reference does not record, like those of untyped values, become interface{}.
+func typeExpr(sym Symbol) string {
+	switch sym.SymbolType {
+	case "type", "alias":
+		return sym.UnderlyingType
+	case "interface":
+		return "interface {\n" + stubMembers(sym) + "}"
+	case "struct":
+		return "struct {\n" + stubMembers(sym) + "}"
+	case "star", "selector", "array", "Map", "chan", "funcType", "variadic", "instance":
+		return sym.Label
+	default:
+		return "interface{}"
+	}
+}
+
+func stubMembers(sym Symbol) string {
+	buf := new(bytes.Buffer)
+	for _, member := range sym.Members {
+		switch member.SymbolType {
+		case "embed":
+			embed := member.Label
+			if member.UnderlyingType != "" {
+				embed = member.UnderlyingType
+			}
+			if !importsRecorded(embed, member.Imports) {
+				fmt.Fprintf(buf, "// embedded %s omitted: its package is not in the reference\n", embed)
+				continue
+			}
+			fmt.Fprintf(buf, "%s\n", embed)
+		case "method":
+			fmt.Fprintf(buf, "%s%s\n", member.Label, stubSignature(member.FuncSpec))
+		default:
+			// references taken before the packages of field types were recorded only
+			// have the names of those packages, which cannot be imported
+			typ := member.UnderlyingType
+			if typ == "" || !importsRecorded(typ, member.Imports) {
+				typ = "interface{}"
+			}
+			fmt.Fprintf(buf, "%s %s\n", member.Label, typ)
+		}
+	}
+	return buf.String()
+}
+
+// importsRecorded reports whether the import path of every package the type expression
+// typ qualifies types with is in imports.
+func importsRecorded(typ string, imports map[string]string) bool {
+	expr, err := parser.ParseExpr(typ)
+	if err != nil {
+		return false
+	}
+	res := true
+	ast.Inspect(expr, func(node ast.Node) bool {
+		if sel, ok := node.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && imports[x.Name] == "" {
+				res = false
+			}
+			return false
+		}
+		return true
+	})
+	return res
+}
+
+// stubSignature renders a signature with named results, so that stub bodies can
+// return zero values with a naked return.
+func stubSignature(spec *FuncSpec) string {
+	if spec == nil {
+		return "()"
+	}
+	params := make([]string, 0)
+	for i, param := range spec.Params {
+		params = append(params, fmt.Sprintf("p%d %s", i, typeExpr(param)))
+	}
+	returns := make([]string, 0)
+	for i, result := range spec.Returns {
+		returns = append(returns, fmt.Sprintf("r%d %s", i, typeExpr(result)))
+	}
+	res := "(" + strings.Join(params, ", ") + ")"
+	if len(returns) > 0 {
+		res += " (" + strings.Join(returns, ", ") + ")"
+	}
+	return res
+}
+
+// stubTypeParams declares type parameters with their positional names and constraints.
+func stubTypeParams(constraints []string) string {
+	if len(constraints) == 0 {
+		return ""
+	}
+	params := make([]string, len(constraints))
+	for i, constraint := range constraints {
+		params[i] = typeParamName(i) + " " + constraint
+	}
+	return "[" + strings.Join(params, ", ") + "]"
+}
+
+// defaultType is the type an untyped constant assumes in a var declaration.
+func defaultType(typ string) string {
+	switch typ {
+	case "untyped float":
+		return "float64"
+	case "untyped complex":
+		return "complex128"
+	case "untyped nil":
+		return "interface{}"
+	default:
+		return strings.TrimPrefix(typ, "untyped ")
+	}
+}
+
+func collectImports(symbols SymbolList, imports map[string]string) {
+	for _, sym := range symbols {
+		if sym.PkgPath != "" {
+			name := strings.TrimPrefix(sym.Label, "*")
+			if sym.SymbolType == "alias" {
+				name = strings.TrimPrefix(sym.UnderlyingType, "*")
+			}
+			if i := strings.Index(name, "."); i >= 0 {
+				imports[name[:i]] = sym.PkgPath
+			}
+		}
+		for name, importPath := range sym.Imports {
+			imports[name] = importPath
+		}
+		collectImports(sym.Members, imports)
+		if sym.ValueType != nil {
+			collectImports(SymbolList{*sym.ValueType}, imports)
+		}
+		if sym.FuncSpec != nil {
+			collectImports(sym.FuncSpec.Params, imports)
+			collectImports(sym.FuncSpec.Returns, imports)
+		}
+	}
+}
+
+// generateStub renders a package declaring every exported symbol of the reference
+// with empty bodies, which consumers can be compiled against.
+func generateStub(symbols SymbolList, pkgName, source string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "// Code generated by symbol-check stub from %s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(buf, "package %s\n\n", pkgName)
+
+	imports := make(map[string]string)
+	collectImports(symbols, imports)
+	names := make([]string, 0, len(imports))
+	for name := range imports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) > 0 {
+		fmt.Fprintln(buf, "import (")
+		for _, name := range names {
+			if importName(imports[name]) == name {
+				fmt.Fprintf(buf, "%q\n", imports[name])
+			} else {
+				fmt.Fprintf(buf, "%s %q\n", name, imports[name])
+			}
+		}
+		fmt.Fprintln(buf, ")")
+	}
+
+	types := make(map[string]bool)
+	typeParams := make(map[string][]string)
+	for _, sym := range symbols {
+		if sym.ReceiverType == "" && sym.SymbolType != "func" && sym.SymbolType != "var" && sym.SymbolType != "const" {
+			types[sym.Label] = true
+			typeParams[sym.Label] = sym.TypeParams
+		}
+	}
+	for _, sym := range symbols {
+		if sym.Unexported {
+			continue
+		}
+		switch sym.SymbolType {
+		case "func":
+			fmt.Fprintf(buf, "\nfunc %s%s%s { return }\n", sym.Label, stubTypeParams(sym.TypeParams), stubSignature(sym.FuncSpec))
+		case "method":
+			if !types[sym.ReceiverType] {
+				fmt.Fprintf(buf, "\n// method %s omitted: receiver type not in reference\n", sym.Ident())
+				continue
+			}
+			receiver := sym.ReceiverType
+			if params := typeParams[sym.ReceiverType]; len(params) > 0 {
+				names := make([]string, len(params))
+				for i := range params {
+					names[i] = typeParamName(i)
+				}
+				receiver += "[" + strings.Join(names, ", ") + "]"
+			}
+			if sym.PointerReceiver {
+				receiver = "*" + receiver
+			}
+			fmt.Fprintf(buf, "\nfunc (%s) %s%s { return }\n", receiver, sym.Label, stubSignature(sym.FuncSpec))
+		case "var", "const":
+			// constants are stubbed as variables, as their values may not be known
+			if sym.ValueType != nil {
+				fmt.Fprintf(buf, "\nvar %s %s\n", sym.Label, defaultType(typeExpr(*sym.ValueType)))
+			} else {
+				fmt.Fprintf(buf, "\nvar %s interface{}\n", sym.Label)
+			}
+		default:
+			name := sym.Label + stubTypeParams(sym.TypeParams)
+			if sym.SymbolType == "alias" {
+				name += " ="
+			}
+			fmt.Fprintf(buf, "\ntype %s %s\n", name, typeExpr(sym))
+		}
+	}
+
+	declarePlaceholders(buf, imports)
+	return format.Source(buf.Bytes())
+}
+
+// declarePlaceholders appends an empty struct type for every type the stub in buf
+// refers to without declaring it, like unexported types of struct fields, which are
+// not part of the reference.
+func declarePlaceholders(buf *bytes.Buffer, imports map[string]string) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", buf.Bytes(), 0)
+	if err != nil {
+		// format.Source reports the error
+		return
+	}
+	undeclared := make(map[string]bool)
+	for _, ident := range file.Unresolved {
+		if imports[ident.Name] == "" && types.Universe.Lookup(ident.Name) == nil {
+			undeclared[ident.Name] = true
+		}
+	}
+	names := make([]string, 0, len(undeclared))
+	for name := range undeclared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(buf, "\n// %s is not in the reference, it is declared so the stub compiles\ntype %s struct{}\n", name, name)
+	}
+}
+
+func runStub(args []string) {
+	flags := flag.NewFlagSet("stub", flag.ExitOnError)
+	reference := flags.String("c", "", "reference snapshot to generate the stub from")
+	outDir := flags.String("o", "", "output directory, the stub is printed if omitted")
+	stubPkg := flags.String("p", "", "package name of the stub, defaults to the name of the output directory")
+	flags.Parse(args)
+	if *reference == "" {
+		exitWithStatusString("stub: -c is required", 1)
+	}
+	if *stubPkg == "" {
+		if *outDir == "" {
+			exitWithStatusString("stub: -p is required when printing the stub", 1)
+		}
+		abs, err := filepath.Abs(*outDir)
+		if err != nil {
+			exitWithStatusError(err, 1)
+		}
+		*stubPkg = filepath.Base(abs)
+	}
+
+	refData, err := loadReference(*reference)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	src, err := generateStub(refData.Symbols, *stubPkg, filepath.Base(*reference))
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+
+	if *outDir == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		exitWithStatusError(err, 1)
+	}
+	if err := ioutil.WriteFile(filepath.Join(*outDir, "stub.go"), src, 0644); err != nil {
+		exitWithStatusError(err, 1)
+	}
+}
@@ -0,0 +1,54 @@
+package exports
+
+import (
+	"fmt"
+	"go/ast"
+)
+
+// surfaceSize is the size of an API: its exported symbols, counting variants for
+// different platforms once, and the exported fields and methods of its structs and
+// interfaces.
+func surfaceSize(symbols SymbolList) int {
+	seen := make(map[string]bool)
+	for _, sym := range symbols {
+		if sym.Unexported || seen[sym.Ident()] {
+			continue
+		}
+		seen[sym.Ident()] = true
+		for _, member := range sym.Members {
+			if ast.IsExported(member.Label) {
+				seen[sym.Ident()+"."+member.Label] = true
+			}
+		}
+	}
+	return len(seen)
+}
+
+// surfaceGated reports whether the size of the API is gated, see checkSurface.
+func (p Policy) surfaceGated() bool {
+	return p.MaxSurfaceGrowth >= 0 || p.MaxSurfaceShrink >= 0
+}
+
+// checkSurface enforces the limits on how much the API may grow or shrink since the
+// reference, in percent of its size.
+func (p Policy) checkSurface(before, after int) error {
+	if p.ReportOnly || before == 0 {
+		return nil
+	}
+	delta := float64(after-before) * 100 / float64(before)
+	switch {
+	case p.MaxSurfaceGrowth >= 0 && delta > p.MaxSurfaceGrowth:
+		return fmt.Errorf("API surface grew by %.1f%%, more than the limit of %g%%", delta, p.MaxSurfaceGrowth)
+	case p.MaxSurfaceShrink >= 0 && -delta > p.MaxSurfaceShrink:
+		return fmt.Errorf("API surface shrank by %.1f%%, more than the limit of %g%%", -delta, p.MaxSurfaceShrink)
+	}
+	return nil
+}
+
+// surfaceDelta describes how the size of the API changed.
+func surfaceDelta(before, after int) string {
+	if before == 0 {
+		return fmt.Sprintf("API surface: %d symbols, none in the reference", after)
+	}
+	return fmt.Sprintf("API surface: %d to %d symbols (%+.1f%%)", before, after, float64(after-before)*100/float64(before))
+}
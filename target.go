@@ -0,0 +1,73 @@
+package exports
+
+import (
+	"go/build"
+	"os"
+	"strings"
+)
+
+// targetOS, targetArch and buildTags select the files compiled for one target, set with
+// -goos, -goarch and -tags. Without any of them, every file is read regardless of its
+// build constraints, which unions the declarations of all platforms, see buildVariants.
+var targetOS, targetArch string
+var buildTags stringList
+
+// targeted reports whether extraction is limited to the files of one target.
+func targeted() bool {
+	return targetOS != "" || targetArch != "" || len(buildTags) > 0
+}
+
+// buildContext is the go/build context of the target, the host for what is not given.
+func buildContext() *build.Context {
+	ctxt := build.Default
+	if targetOS != "" {
+		ctxt.GOOS = targetOS
+	}
+	if targetArch != "" {
+		ctxt.GOARCH = targetArch
+	}
+	if ctxt.GOOS != build.Default.GOOS || ctxt.GOARCH != build.Default.GOARCH {
+		// cross compiling disables cgo unless asked for
+		ctxt.CgoEnabled = buildTags.contains("cgo")
+	}
+	ctxt.BuildTags = buildTags
+	return &ctxt
+}
+
+// targetName describes the target, like linux/arm64 with tags netgo, empty without one.
+func targetName() string {
+	if !targeted() {
+		return ""
+	}
+	ctxt := buildContext()
+	res := ctxt.GOOS + "/" + ctxt.GOARCH
+	if len(buildTags) > 0 {
+		res += " with tags " + strings.Join(buildTags, ",")
+	}
+	return res
+}
+
+// describeTarget names a target in messages.
+func describeTarget(target string) string {
+	if target == "" {
+		return "every platform"
+	}
+	return target
+}
+
+// builtForTarget reports whether a file of dir is compiled for the target, by its name
+// and build constraints. Every file is without a target.
+func builtForTarget(dir, name string) bool {
+	if !targeted() {
+		return true
+	}
+	ok, err := buildContext().MatchFile(dir, name)
+	return err == nil && ok
+}
+
+// sourceFilter selects the source files of dir for parser.ParseDir.
+func sourceFilter(dir string) func(os.FileInfo) bool {
+	return func(info os.FileInfo) bool {
+		return isSourceFile(info) && builtForTarget(dir, info.Name())
+	}
+}
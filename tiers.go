@@ -0,0 +1,81 @@
+package exports
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Stability tiers of packages. Findings in stable packages are rated by the policy,
+// breaking findings in beta packages are only warnings, and every finding in alpha
+// packages is informational.
+const (
+	tierStable = "stable"
+	tierBeta   = "beta"
+	tierAlpha  = "alpha"
+)
+
+// tiers are the -tier values, pattern=tier, in order of precedence.
+var tiers stringList
+
+// parseTiers checks -tier values.
+func parseTiers(values []string) error {
+	for _, value := range values {
+		pattern, tier, ok := strings.Cut(value, "=")
+		if !ok || pattern == "" {
+			return fmt.Errorf("-tier takes pattern=tier, like example.com/mod/exp/...=alpha, got %s", value)
+		}
+		switch tier {
+		case tierStable, tierBeta, tierAlpha:
+		default:
+			return fmt.Errorf("unknown tier %s, use stable, beta or alpha", tier)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad tier pattern %s: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchesPackage reports whether a package, named by import path or directory, matches
+// pattern. Patterns ending in /... match the package and every package below it, as
+// they do for the go command, others use the syntax of path.Match.
+func matchesPackage(pattern, name string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/..."); ok {
+		return name == prefix || strings.HasPrefix(name, prefix+"/")
+	}
+	matched, _ := path.Match(pattern, name)
+	return matched
+}
+
+// tierOf returns the tier of the first -tier pattern matching the import path or the
+// directory of the package checked, stable if none does.
+func tierOf(check packageCheck) string {
+	names := []string{filepath.ToSlash(filepath.Clean(check.Dir))}
+	if check.ImportPath != "" {
+		names = append(names, check.ImportPath)
+	}
+	for _, value := range tiers {
+		pattern, tier, _ := strings.Cut(value, "=")
+		for _, name := range names {
+			if matchesPackage(strings.TrimPrefix(pattern, "./"), name) {
+				return tier
+			}
+		}
+	}
+	return tierStable
+}
+
+// rateForTier adjusts the severities of findings in a package of the given tier.
+func rateForTier(tier string, diffs []Diff) []Diff {
+	for i := range diffs {
+		switch {
+		case tier == tierAlpha:
+			diffs[i].Severity = SeverityInfo
+		case tier == tierBeta && diffs[i].Severity == SeverityBreaking:
+			diffs[i].Severity = SeverityWarning
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,53 @@
+package exports
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// tracing enables -vv, which logs every pair of symbols compared and what the rules
+// made of it, for reports of missed or false findings. Traces are debug logs.
+var tracing bool
+
+func tracef(format string, a ...interface{}) {
+	if tracing {
+		slog.Debug(fmt.Sprintf(format, a...))
+	}
+}
+
+// traceName describes a symbol in traces. Parameters and results are unnamed, so they are described by their type.
+func traceName(sym *Symbol) string {
+	if sym.Label == "" {
+		return typeExpr(*sym)
+	}
+	return sym.String()
+}
+
+// traceSymbol logs the outcome of comparing the reference symbol a to the current symbol b.
+func traceSymbol(a, b Symbol, diffs []Diff) {
+	if !tracing {
+		return
+	}
+	if len(diffs) == 0 {
+		tracef("%s (%s) matches %s (%s): no findings", traceName(&a), a.SymbolType, traceName(&b), b.SymbolType)
+		return
+	}
+	tracef("%s (%s) matches %s (%s), findings:", traceName(&a), a.SymbolType, traceName(&b), b.SymbolType)
+	for _, diff := range diffs {
+		tracef("\t%s, %s: %s", diff.category(), diff.Severity, diff.Message)
+	}
+}
+
+// traceVerdict logs how the policy rates each finding of a comparison.
+func traceVerdict(diffs []Diff) {
+	if !tracing {
+		return
+	}
+	for _, diff := range diffs {
+		verdict := "passes"
+		if policy.fails(diff) {
+			verdict = "fails"
+		}
+		tracef("policy: %s %s %s, %s: %s", diff.Symbol, diff.category(), diff.Severity, verdict, diffFingerprint(diff))
+	}
+}
@@ -0,0 +1,67 @@
+package exports
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// oldTree and newTree are source trees compared directly with -old and -new, each a
+// directory or an import path.
+var oldTree, newTree string
+
+// treeDir resolves a source tree given as a directory or as an import path, which is
+// looked up with go list from the work dir.
+func treeDir(tree string) (string, error) {
+	if info, err := os.Stat(tree); err == nil && info.IsDir() {
+		return tree, nil
+	}
+	cmd := exec.Command("go", "list", "-f", "{{.Dir}}", tree)
+	cmd.Dir = workDir
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("cannot find package %s: %s", tree, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// useTrees points the work dir at the -new tree and the reference at the -old one.
+func useTrees() error {
+	if len(compareTo) > 0 || len(packages) > 0 || recursive || storeSpec != "" || againstRef != "" {
+		return fmt.Errorf("-old cannot be combined with -c, -package, -r, -store or -against")
+	}
+	oldDir, err := treeDir(oldTree)
+	if err != nil {
+		return err
+	}
+	if newTree != "" {
+		newDir, err := treeDir(newTree)
+		if err != nil {
+			return err
+		}
+		workDir = newDir
+	}
+	compareTo = stringList{oldDir}
+	return nil
+}
+
+// loadTree extracts the symbols of a source tree to compare against, the way a
+// snapshot taken from it would record them.
+func loadTree(dir string) (*Baseline, error) {
+	exports, err := extract(dir, pkgName)
+	if err != nil {
+		return nil, err
+	}
+	if !captureDocs {
+		exports = withoutDocs(exports)
+	}
+	for i := range exports {
+		exports[i].Frozen = freeze.matches(exports[i])
+	}
+	annotateSymbols(exports)
+	return &Baseline{Symbols: exports, tree: true}, nil
+}
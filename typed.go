@@ -0,0 +1,418 @@
+package exports
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	gopackages "golang.org/x/tools/go/packages"
+)
+
+// typeResolver is the typed backend: it loads the current package with go/packages and
+// type-checks it, so that types recorded in a reference can be resolved and related to
+// current ones.
+type typeResolver struct {
+	dir    string
+	pkg    *types.Package
+	config *gopackages.Config
+	// imported are the packages the current one depends on, by import path, and those
+	// loaded since to look up types of a reference
+	imported map[string]*types.Package
+	// exprTypes are the types of the type expressions of the package, keyed by exprKey
+	exprTypes map[string]types.Type
+	// mu guards imported, when references are compared concurrently
+	mu sync.Mutex
+}
+
+// resolver is nil unless the typed backend is enabled with -typed.
+var resolver *typeResolver
+
+func newTypeResolver(dir, pkgName string) (*typeResolver, error) {
+	config := &gopackages.Config{
+		// dependencies are type-checked from source too, rather than read from export data
+		// of the go command, whose format changes with go releases
+		Mode: gopackages.NeedName | gopackages.NeedImports | gopackages.NeedDeps | gopackages.NeedTypes | gopackages.NeedTypesInfo | gopackages.NeedSyntax,
+		Dir:  dir,
+		Fset: token.NewFileSet(),
+	}
+	// the package is loaded for the target like the go command builds it, the host without one
+	if targeted() {
+		ctxt := buildContext()
+		config.Env = append(os.Environ(), "GOOS="+ctxt.GOOS, "GOARCH="+ctxt.GOARCH)
+		if !ctxt.CgoEnabled {
+			config.Env = append(config.Env, "CGO_ENABLED=0")
+		}
+		config.BuildFlags = []string{"-tags=" + strings.Join(buildTags, ",")}
+	}
+	pkgs, err := gopackages.Load(config, ".")
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 || pkgName != "" && pkgs[0].Name != pkgName {
+		return nil, fmt.Errorf("-typed loads the package the go command builds in %s, which is not %s", dir, pkgName)
+	}
+	pkg := pkgs[0]
+	// every error is collected, as types of a package that does not type-check may be
+	// invalid, and comparing them would report changes that are not there or miss some
+	if len(pkg.Errors) > 0 {
+		typeErrors := make([]string, 0, len(pkg.Errors))
+		for _, err := range pkg.Errors {
+			// the go command reports the errors of type-checking too, in its own words
+			if err.Kind != gopackages.ListError {
+				typeErrors = append(typeErrors, err.Error())
+			}
+		}
+		if len(typeErrors) == 0 {
+			for _, err := range pkg.Errors {
+				typeErrors = append(typeErrors, err.Error())
+			}
+		}
+		const shown = 10
+		if len(typeErrors) > shown {
+			typeErrors = append(typeErrors[:shown], fmt.Sprintf("and %d more", len(typeErrors)-shown))
+		}
+		return nil, fmt.Errorf("-typed needs package %s to type-check, but it does not:\n\t%s", pkg.Name, strings.Join(typeErrors, "\n\t"))
+	}
+	imported := make(map[string]*types.Package)
+	var visit func(pkg *types.Package)
+	visit = func(pkg *types.Package) {
+		for _, dep := range pkg.Imports() {
+			if imported[dep.Path()] == nil {
+				imported[dep.Path()] = dep
+				visit(dep)
+			}
+		}
+	}
+	visit(pkg.Types)
+	exprTypes := make(map[string]types.Type)
+	for expr, tv := range pkg.TypesInfo.Types {
+		if tv.IsType() {
+			exprTypes[exprKey(config.Fset, expr)] = tv.Type
+		}
+	}
+	return &typeResolver{dir: dir, pkg: pkg.Types, config: config, imported: imported, exprTypes: exprTypes}, nil
+}
+
+// importPackage returns the package with import path pkgPath, loading it unless the
+// current package depends on it.
+func (r *typeResolver) importPackage(pkgPath string) *types.Package {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if pkg, ok := r.imported[pkgPath]; ok {
+		return pkg
+	}
+	config := *r.config
+	config.Mode = gopackages.NeedName | gopackages.NeedImports | gopackages.NeedDeps | gopackages.NeedTypes
+	pkgs, err := gopackages.Load(&config, pkgPath)
+	if err != nil || len(pkgs) != 1 || len(pkgs[0].Errors) > 0 {
+		// packages that cannot be loaded are not tried again
+		r.imported[pkgPath] = nil
+		return nil
+	}
+	r.imported[pkgPath] = pkgs[0].Types
+	return pkgs[0].Types
+}
+
+// exprKey identifies an expression by its extent in its file, which is the same
+// however often the file is parsed. Files are named by their base name, as the
+// go command names them by absolute path and extraction by the directory it is given.
+func exprKey(fset *token.FileSet, expr ast.Expr) string {
+	start, end := fset.Position(expr.Pos()), fset.Position(expr.End())
+	return fmt.Sprintf("%s:%d:%d", filepath.Base(start.Filename), start.Offset, end.Offset)
+}
+
+// canonicalType renders a type with every alias resolved, packages named by their
+// import path and type parameters by position, so identical types render the same
+// however they are spelled, like []byte and []uint8.
+func (r *typeResolver) canonicalType(typ types.Type) string {
+	tuple := func(t *types.Tuple, variadic bool) string {
+		res := make([]string, t.Len())
+		for i := range res {
+			if variadic && i == len(res)-1 {
+				res[i] = "..." + r.canonicalType(t.At(i).Type().(*types.Slice).Elem())
+			} else {
+				res[i] = r.canonicalType(t.At(i).Type())
+			}
+		}
+		return "(" + strings.Join(res, ", ") + ")"
+	}
+	switch t := typ.(type) {
+	case *types.Alias:
+		return r.canonicalType(types.Unalias(t))
+	case *types.Basic:
+		// byte and rune are spellings of uint8 and int32
+		return types.Typ[t.Kind()].Name()
+	case *types.Named:
+		res := t.Obj().Name()
+		if pkg := t.Obj().Pkg(); pkg != nil && pkg != r.pkg {
+			res = pkg.Path() + "." + res
+		}
+		if args := t.TypeArgs(); args.Len() > 0 {
+			list := make([]string, args.Len())
+			for i := range list {
+				list[i] = r.canonicalType(args.At(i))
+			}
+			res += "[" + strings.Join(list, ", ") + "]"
+		}
+		return res
+	case *types.TypeParam:
+		return typeParamName(t.Index())
+	case *types.Pointer:
+		return "*" + r.canonicalType(t.Elem())
+	case *types.Slice:
+		return "[]" + r.canonicalType(t.Elem())
+	case *types.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), r.canonicalType(t.Elem()))
+	case *types.Map:
+		return "map[" + r.canonicalType(t.Key()) + "]" + r.canonicalType(t.Elem())
+	case *types.Chan:
+		prefix := map[types.ChanDir]string{types.SendRecv: "chan ", types.SendOnly: "chan<- ", types.RecvOnly: "<-chan "}[t.Dir()]
+		return prefix + r.canonicalType(t.Elem())
+	case *types.Signature:
+		return "func" + tuple(t.Params(), t.Variadic()) + tuple(t.Results(), false)
+	case *types.Struct:
+		fields := make([]string, t.NumFields())
+		for i := range fields {
+			field := t.Field(i)
+			fields[i] = r.canonicalType(field.Type())
+			if !field.Embedded() {
+				fields[i] = field.Name() + " " + fields[i]
+			}
+			if tag := t.Tag(i); tag != "" {
+				fields[i] += " " + strconv.Quote(tag)
+			}
+		}
+		return "struct{" + strings.Join(fields, "; ") + "}"
+	case *types.Interface:
+		elems := make([]string, 0, t.NumEmbeddeds()+t.NumExplicitMethods())
+		for i := 0; i < t.NumEmbeddeds(); i++ {
+			elems = append(elems, r.canonicalType(t.EmbeddedType(i)))
+		}
+		for i := 0; i < t.NumExplicitMethods(); i++ {
+			method := t.ExplicitMethod(i)
+			elems = append(elems, method.Name()+strings.TrimPrefix(r.canonicalType(method.Type()), "func"))
+		}
+		return "interface{" + strings.Join(elems, "; ") + "}"
+	case *types.Union:
+		terms := make([]string, t.Len())
+		for i := range terms {
+			terms[i] = r.canonicalType(t.Term(i).Type())
+			if t.Term(i).Tilde() {
+				terms[i] = "~" + terms[i]
+			}
+		}
+		return strings.Join(terms, " | ")
+	default:
+		return types.TypeString(t, func(pkg *types.Package) string { return pkg.Path() })
+	}
+}
+
+// resolved returns the canonical form of the type a type expression denotes, or an
+// empty string if the type is unknown, like without the typed backend.
+func (r *typeResolver) resolved(fset *token.FileSet, expr ast.Expr) string {
+	if r == nil {
+		return ""
+	}
+	typ, ok := r.exprTypes[exprKey(fset, expr)]
+	if !ok {
+		return ""
+	}
+	return r.canonicalType(typ)
+}
+
+// annotate records in sym, which was extracted from expr, the resolved types of its
+// type expressions: parameters and results of functions, types of struct fields and
+// signatures of interface methods, or the type expr denotes for anything else.
+func (r *typeResolver) annotate(fset *token.FileSet, expr ast.Expr, sym *Symbol) {
+	if r == nil {
+		return
+	}
+	if funcType, ok := expr.(*ast.FuncType); ok && sym.FuncSpec != nil {
+		r.annotateFields(fset, funcType.Params, sym.FuncSpec.Params)
+		r.annotateFields(fset, funcType.Results, sym.FuncSpec.Returns)
+		return
+	}
+	switch expr := expr.(type) {
+	case *ast.StructType:
+		// members are recorded one per name, and one per embedded field
+		i := 0
+		for _, field := range expr.Fields.List {
+			resolved := r.resolved(fset, field.Type)
+			for j := 0; j < len(field.Names) || j == 0; j++ {
+				if len(field.Names) > 0 {
+					sym.Members[i].Resolved = resolved
+				}
+				i++
+			}
+		}
+	case *ast.InterfaceType:
+		for i, method := range expr.Methods.List {
+			if len(method.Names) > 0 {
+				r.annotate(fset, method.Type, &sym.Members[i])
+			}
+		}
+	default:
+		sym.Resolved = r.resolved(fset, expr)
+	}
+}
+
+func (r *typeResolver) annotateFields(fset *token.FileSet, list *ast.FieldList, symbols SymbolList) {
+	if list == nil {
+		return
+	}
+	i := 0
+	for _, field := range list.List {
+		resolved := r.resolved(fset, field.Type)
+		for j := 0; j < len(field.Names) || j == 0; j++ {
+			symbols[i].Resolved = resolved
+			i++
+		}
+	}
+}
+
+// lookup finds a named type. Types recorded without an import path are looked
+// up among the packages imported by the current package.
+func (r *typeResolver) lookup(pkgPath, qualifier, name string) types.Type {
+	var scope *types.Scope
+	switch {
+	case qualifier == "":
+		scope = r.pkg.Scope()
+	case pkgPath != "":
+		imported := r.importPackage(pkgPath)
+		if imported == nil {
+			return nil
+		}
+		scope = imported.Scope()
+	default:
+		for _, imported := range r.pkg.Imports() {
+			if imported.Name() == qualifier {
+				scope = imported.Scope()
+			}
+		}
+	}
+	if scope == nil {
+		return nil
+	}
+	obj := scope.Lookup(name)
+	if obj == nil {
+		obj = types.Universe.Lookup(name)
+	}
+	if typeName, ok := obj.(*types.TypeName); ok {
+		return typeName.Type()
+	}
+	return nil
+}
+
+func (r *typeResolver) lookupQualified(pkgPath, name string) types.Type {
+	if i := strings.Index(name, "."); i >= 0 {
+		return r.lookup(pkgPath, name[:i], name[i+1:])
+	}
+	return r.lookup(pkgPath, "", name)
+}
+
+// resolve returns the type recorded in sym, or nil if it cannot be resolved.
+func (r *typeResolver) resolve(sym Symbol) types.Type {
+	switch sym.SymbolType {
+	case "type":
+		return r.lookup("", "", sym.UnderlyingType)
+	case "selector":
+		return r.lookupQualified(sym.PkgPath, sym.Label)
+	case "star":
+		if elem := r.lookupQualified(sym.PkgPath, strings.TrimPrefix(sym.Label, "*")); elem != nil {
+			return types.NewPointer(elem)
+		}
+	case "interface":
+		if len(sym.Members) == 0 {
+			return types.NewInterfaceType(nil, nil).Complete()
+		}
+	}
+	return nil
+}
+
+// widenedParams reports parameters whose type became an interface the old type
+// implements. Such changes keep every call compiling, so they are only warnings.
+// It returns nil unless every changed parameter is such a widening.
+func (c comparer) widenedParams(old, new SymbolList) []Diff {
+	r := c.resolver
+	if r == nil || len(old) != len(new) {
+		return nil
+	}
+	severity := SeverityWarning
+	if c.Policy.StrictFuncs {
+		severity = SeverityBreaking
+	}
+	diffs := make([]Diff, 0)
+	for i := range old {
+		if c.sameSymbol(old[i], new[i]) {
+			continue
+		}
+		oldType, newType := r.resolve(old[i]), r.resolve(new[i])
+		if oldType == nil || newType == nil || !types.IsInterface(newType) || !types.AssignableTo(oldType, newType) {
+			return nil
+		}
+		diffs = append(diffs, Diff{
+			Kind:     DiffChanged,
+			Message:  fmt.Sprintf("widened from %s to %s", typeExpr(old[i]), typeExpr(new[i])),
+			Path:     []string{fmt.Sprintf("param %d", i)},
+			Pointer:  fmt.Sprintf("/funcSpec/params/%d", i),
+			Severity: severity,
+			Category: "widened",
+		})
+	}
+	if len(diffs) == 0 {
+		return nil
+	}
+	return diffs
+}
+
+// symbolFromType records a type in the same form formatType records type expressions.
+func (r *typeResolver) symbolFromType(typ types.Type) *Symbol {
+	qualifier := func(pkg *types.Package) string {
+		if pkg == r.pkg {
+			return ""
+		}
+		return pkg.Name()
+	}
+	switch t := typ.(type) {
+	case *types.Basic:
+		return &Symbol{SymbolType: "type", UnderlyingType: t.Name()}
+	case *types.Named:
+		if pkg := t.Obj().Pkg(); pkg != nil && pkg != r.pkg {
+			return &Symbol{Label: types.TypeString(t, qualifier), SymbolType: "selector", PkgPath: pkg.Path()}
+		}
+		return &Symbol{SymbolType: "type", UnderlyingType: t.Obj().Name()}
+	case *types.Pointer:
+		res := &Symbol{Label: types.TypeString(t, qualifier), SymbolType: "star"}
+		if named, ok := t.Elem().(*types.Named); ok && named.Obj().Pkg() != nil && named.Obj().Pkg() != r.pkg {
+			res.PkgPath = named.Obj().Pkg().Path()
+		}
+		return res
+	case *types.Slice, *types.Array:
+		return &Symbol{Label: types.TypeString(t, qualifier), SymbolType: "array"}
+	case *types.Map:
+		return &Symbol{Label: types.TypeString(t, qualifier), SymbolType: "Map"}
+	default:
+		return &Symbol{SymbolType: "type", UnderlyingType: types.TypeString(t, qualifier)}
+	}
+}
+
+// inferredType returns the type of a package level var or const declared without one.
+// It returns nil without the typed backend or for packages other than the checked one.
+func (r *typeResolver) inferredType(dir, name string) *Symbol {
+	if r == nil || dir != r.dir {
+		return nil
+	}
+	obj := r.pkg.Scope().Lookup(name)
+	if obj == nil {
+		return nil
+	}
+	res := r.symbolFromType(obj.Type())
+	res.Resolved = r.canonicalType(obj.Type())
+	return res
+}
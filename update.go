@@ -0,0 +1,103 @@
+package exports
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// runUpdate takes the snapshot of a package again in place of its reference, after
+// showing what changed. Breaking changes are only written with -y.
+func runUpdate(args []string) {
+	flags := flag.NewFlagSet("update", flag.ExitOnError)
+	reference := flags.String("c", "", "snapshot file to update in place")
+	dir := flags.String("d", "./", "work dir")
+	pkg := flags.String("p", "", "package name - can be omitted if only one package exists")
+	why := flags.String("reason", "", "why the snapshot is updated, recorded in it")
+	yes := flags.Bool("y", false, "update the snapshot even if the changes break consumers")
+	flags.BoolVar(yes, "force", false, "same as -y")
+	flags.Parse(args)
+	if *reference == "" {
+		exitWithStatusString("update: -c is required", 1)
+	}
+	if _, _, ok := splitPackageReference(*reference); ok || strings.HasPrefix(*reference, gitRefPrefix) {
+		exitWithStatusString("update: -c must name a snapshot file, module snapshots are taken again with -r", 1)
+	}
+	refData, err := loadReference(*reference)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	if refData.tree {
+		exitWithStatusString("update: -c must name a snapshot file, not a directory", 1)
+	}
+
+	// the updated snapshot records what the reference did
+	if meta := refData.Meta; meta != nil {
+		captureDocs, zeroValues, constValues = meta.Docs, meta.ZeroValues, meta.ConstValues
+	}
+	exports, err := snapshotSymbols(*dir, *pkg)
+	if err != nil {
+		exitWithStatusError(err, 1)
+	}
+	for i := range exports {
+		for _, old := range refData.Symbols {
+			if old.Ident() == exports[i].Ident() {
+				exports[i].Frozen = exports[i].Frozen || old.Frozen
+				exports[i].Optional = exports[i].Optional || old.Optional
+				exports[i].Metadata = mergeMetadata(old.Metadata, exports[i].Metadata)
+				exports[i].ABISensitive = exports[i].ABISensitive || old.ABISensitive
+			}
+		}
+	}
+	cmp := compareReference(*reference, exports)
+	if cmp.Err != nil {
+		exitWithStatusError(cmp.Err, 1)
+	}
+	if cmp.Meta != nil {
+		fmt.Fprintf(os.Stderr, "updating %s, %s\n", *reference, cmp.Meta)
+	}
+	if len(cmp.Diffs) == 0 {
+		exitWithStatusString("snapshot is up to date", 0)
+	}
+	printDiffSections(os.Stderr, cmp.Diffs)
+	breaking := 0
+	for _, diff := range cmp.Diffs {
+		if diff.Class() == ClassBreaking {
+			breaking++
+		}
+	}
+	if breaking > 0 && !*yes {
+		exitWithStatusString(fmt.Sprintf("%d breaking changes, rerun with -y to update the snapshot anyway", breaking), 2)
+	}
+
+	baseline := &Baseline{Schema: baselineSchema, Symbols: exports}
+	baseline.Meta = newBaselineMeta(*dir, *why)
+	baseline.Meta.recorded()
+	if refData.Meta != nil {
+		baseline.Meta.Contract, baseline.Meta.FrozenOn = refData.Meta.Contract, refData.Meta.FrozenOn
+	}
+	if refData.Package != nil {
+		if baseline.Package, err = packageIdentity(*dir, *pkg); err != nil {
+			exitWithStatusError(err, 1)
+		}
+	}
+	// snapshots taken with -canonical or -no-positions stay that way
+	if refData.Meta != nil && refData.Meta.GeneratedAt.IsZero() {
+		positionless = len(refData.Symbols) > 0 && refData.Symbols[0].FileName == ""
+		canonicalize(baseline)
+	}
+	data, err := json.Marshal(baseline)
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(*reference, append(data, '\n'), 0644); err != nil {
+		exitWithStatusError(err, 1)
+	}
+	if _, err := os.Stat(*reference + signatureSuffix); err == nil {
+		slog.Warn(fmt.Sprintf("the signatures in %s%s no longer match, sign the snapshot again", *reference, signatureSuffix), "signatures", *reference+signatureSuffix)
+	}
+	exitWithStatusString("updated "+*reference, 0)
+}
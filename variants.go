@@ -0,0 +1,47 @@
+package exports
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// buildVariants reports exported symbols declared in several files, like foo_linux.go and
+// foo_windows.go, that do not agree. All files are parsed regardless of build constraints,
+// so every variant is in symbols; which one a consumer gets depends on the platform.
+func buildVariants(symbols SymbolList) []Diff {
+	byIdent := make(map[string][]*Symbol)
+	idents := make([]string, 0)
+	for i := range symbols {
+		sym := &symbols[i]
+		if sym.Unexported {
+			continue
+		}
+		if _, ok := byIdent[sym.Ident()]; !ok {
+			idents = append(idents, sym.Ident())
+		}
+		byIdent[sym.Ident()] = append(byIdent[sym.Ident()], sym)
+	}
+	res := make([]Diff, 0)
+	for _, ident := range idents {
+		variants := byIdent[ident]
+		for _, variant := range variants[1:] {
+			diffs := flagComparer().compareSymbol(*variants[0], *variant, true)
+			if len(diffs) == 0 {
+				continue
+			}
+			messages := make([]string, 0, len(diffs))
+			for _, diff := range diffs {
+				messages = append(messages, strings.Join(append(diff.Path, diff.Message), ": "))
+			}
+			res = append(res, Diff{
+				Kind:     DiffInconsistent,
+				Symbol:   ident,
+				Message:  fmt.Sprintf("declared differently in %s and %s: %s", filepath.Base(variants[0].FileName), filepath.Base(variant.FileName), strings.Join(messages, "; ")),
+				Severity: SeverityWarning,
+				New:      variant,
+			})
+		}
+	}
+	return res
+}
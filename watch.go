@@ -0,0 +1,61 @@
+package exports
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// watchInterval is how often watched files are polled for changes.
+const watchInterval = time.Second
+
+// watchedFiles lists the files a comparison depends on: the Go files of every
+// package, reference snapshots on disk and the suppression file, which is read
+// again on every comparison.
+func watchedFiles(checks []packageCheck) []string {
+	res := make([]string, 0)
+	for _, check := range checks {
+		if files, err := filepath.Glob(filepath.Join(check.Dir, "*.go")); err == nil {
+			res = append(res, files...)
+		}
+		if baselineStore == nil {
+			res = append(res, check.References...)
+		}
+	}
+	if suppressFile != "" {
+		res = append(res, suppressFile)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// watchState identifies the contents of files by their size and modification time.
+// Files that do not exist, like a suppression file being rewritten, are part of the state.
+func watchState(files []string) string {
+	state := make([]string, 0, len(files))
+	for _, fileName := range files {
+		if info, err := os.Stat(fileName); err == nil {
+			state = append(state, fmt.Sprintf("%s %d %d", fileName, info.Size(), info.ModTime().UnixNano()))
+		} else {
+			state = append(state, fileName+" missing")
+		}
+	}
+	return strings.Join(state, "\n")
+}
+
+// watchChecks compares the packages again whenever a file they depend on changes,
+// until interrupted. Edits to the suppression file take effect without a restart.
+func watchChecks(checks []packageCheck, multiple bool) {
+	last := ""
+	for {
+		if state := watchState(watchedFiles(checks)); state != last {
+			last = state
+			_, verdict := evaluateChecks(os.Stderr, checks, multiple)
+			fmt.Fprintf(os.Stderr, "%s (%s)\n\n", verdict, time.Now().Format("15:04:05"))
+		}
+		time.Sleep(watchInterval)
+	}
+}
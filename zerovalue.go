@@ -0,0 +1,42 @@
+package exports
+
+import (
+	"fmt"
+	"go/ast"
+)
+
+// zeroValues records, and compares, whether the zero values of exported struct types
+// are usable, see zeroUnsafeField.
+var zeroValues bool
+
+// zeroUnsafeField returns the first field, as name and type, that makes the zero value
+// of a struct unusable, or "" for structs that can be used without a constructor. This
+// is a heuristic: unexported fields of map, chan and func types can only be set by the
+// package, and a nil map panics on writes, a nil chan blocks forever and a nil func
+// panics when called.
+func zeroUnsafeField(st *ast.StructType) string {
+	for _, field := range st.Fields.List {
+		switch field.Type.(type) {
+		case *ast.MapType, *ast.ChanType, *ast.FuncType:
+		default:
+			continue
+		}
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				return fmt.Sprintf("%s %s", name.Name, exprString(field.Type))
+			}
+		}
+	}
+	return ""
+}
+
+// withoutZeroValues copies symbols leaving out whether their zero values are usable,
+// for references which did not record it.
+func withoutZeroValues(symbols SymbolList) SymbolList {
+	res := make(SymbolList, len(symbols))
+	for i, sym := range symbols {
+		sym.ZeroUnsafe = ""
+		res[i] = sym
+	}
+	return res
+}